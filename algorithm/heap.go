@@ -10,8 +10,11 @@ type heapItem[K comparable, V constraints.Ordered] interface {
 }
 
 // 小顶堆
+// 维护key到下标的索引, Find为O(1), Remove为O(logn)
+// Items只读, 直接修改会破坏索引
 type Heap[K comparable, V constraints.Ordered, T heapItem[K, V]] struct {
 	Items []T
+	index map[K]int
 }
 
 func (h *Heap[K, V, T]) Top() (top T) {
@@ -22,7 +25,11 @@ func (h *Heap[K, V, T]) Top() (top T) {
 }
 
 func (h *Heap[K, V, T]) Push(x T) {
+	if h.index == nil {
+		h.index = map[K]int{}
+	}
 	h.Items = append(h.Items, x)
+	h.index[x.Key()] = h.Len() - 1
 	h.up(h.Len() - 1)
 }
 
@@ -35,6 +42,7 @@ func (h *Heap[K, V, T]) Pop() (item T) {
 	h.swap(0, n)
 	h.down(0, n)
 	h.Items = h.Items[:n]
+	delete(h.index, item.Key())
 	return item
 }
 
@@ -56,6 +64,7 @@ func (h *Heap[K, V, T]) RemoveByIndex(i int) (item T) {
 	}
 	item = h.Items[n]
 	h.Items = h.Items[:n]
+	delete(h.index, item.Key())
 	return item
 }
 
@@ -64,10 +73,8 @@ func (h *Heap[K, V, T]) Len() int {
 }
 
 func (h *Heap[K, V, T]) Find(key K) int {
-	for index, item := range h.Items {
-		if item.Key() == key {
-			return index
-		}
+	if index, ok := h.index[key]; ok {
+		return index
 	}
 	return -1
 }
@@ -105,6 +112,8 @@ func (h *Heap[K, V, T]) down(i0, n int) bool {
 
 func (h *Heap[K, V, T]) swap(i, j int) {
 	h.Items[i], h.Items[j] = h.Items[j], h.Items[i]
+	h.index[h.Items[i].Key()] = i
+	h.index[h.Items[j].Key()] = j
 }
 
 func (h *Heap[K, V, T]) less(i, j int) bool {
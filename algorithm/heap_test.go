@@ -0,0 +1,158 @@
+package algorithm
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+type benchItem struct {
+	id uint64
+	v  int64
+}
+
+func (i *benchItem) Key() uint64 {
+	return i.id
+}
+
+func (i *benchItem) Value() int64 {
+	return i.v
+}
+
+func newBenchHeap(n int) (*Heap[uint64, int64, *benchItem], []uint64) {
+	h := &Heap[uint64, int64, *benchItem]{}
+	keys := make([]uint64, n)
+	for i := 0; i < n; i++ {
+		keys[i] = uint64(i + 1)
+		h.Push(&benchItem{id: keys[i], v: rand.Int63()})
+	}
+	return h, keys
+}
+
+func BenchmarkHeapPush(b *testing.B) {
+	h := &Heap[uint64, int64, *benchItem]{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Push(&benchItem{id: uint64(i + 1), v: rand.Int63()})
+	}
+}
+
+func BenchmarkHeapPop(b *testing.B) {
+	h, _ := newBenchHeap(b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Pop()
+	}
+}
+
+// 1e5个元素时按key删除再放回 删除前需要Find定位, 索引使其为O(1)
+func BenchmarkHeapRemove1e5(b *testing.B) {
+	const n = 100000
+	h, keys := newBenchHeap(n)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := keys[rand.Intn(n)]
+		h.Push(h.Remove(key))
+	}
+}
+
+func BenchmarkHeapFind1e5(b *testing.B) {
+	const n = 100000
+	h, keys := newBenchHeap(n)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if h.Find(keys[i%n]) == -1 {
+			b.Fatal("key not found")
+		}
+	}
+}
+
+// 校验堆序和索引
+func checkHeap(t *testing.T, h *Heap[uint64, int64, *benchItem]) {
+	t.Helper()
+	if len(h.index) != h.Len() {
+		t.Fatalf("index size %d, heap size %d", len(h.index), h.Len())
+	}
+	for i, item := range h.Items {
+		if h.Find(item.Key()) != i {
+			t.Fatalf("key %d index %d, want %d", item.Key(), h.Find(item.Key()), i)
+		}
+		if i > 0 && item.Value() < h.Items[(i-1)/2].Value() {
+			t.Fatalf("heap order broken at %d", i)
+		}
+	}
+}
+
+func TestHeapFind(t *testing.T) {
+	h, keys := newBenchHeap(100)
+	checkHeap(t, h)
+	for i := 0; i < 30; i++ {
+		h.Pop()
+		checkHeap(t, h)
+	}
+	if h.Find(0) != -1 {
+		t.Fatal("find missing key")
+	}
+	found := 0
+	for _, key := range keys {
+		if h.Find(key) != -1 {
+			found++
+		}
+	}
+	if found != 70 {
+		t.Fatalf("found %d keys, want 70", found)
+	}
+}
+
+func TestHeapRemove(t *testing.T) {
+	cases := []struct {
+		name  string
+		index func(n int) int
+	}{
+		{"top", func(int) int { return 0 }},
+		{"last", func(n int) int { return n - 1 }},
+		{"middle", func(n int) int { return n / 2 }},
+		{"random", func(n int) int { return rand.Intn(n) }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h, _ := newBenchHeap(200)
+			for h.Len() > 0 {
+				i := c.index(h.Len())
+				want := h.Items[i]
+				if got := h.RemoveByIndex(i); got != want {
+					t.Fatalf("remove index %d got key %d, want %d", i, got.Key(), want.Key())
+				}
+				if h.Find(want.Key()) != -1 {
+					t.Fatalf("removed key %d still indexed", want.Key())
+				}
+				checkHeap(t, h)
+			}
+		})
+	}
+}
+
+func TestHeapRemoveByKey(t *testing.T) {
+	h := &Heap[uint64, int64, *benchItem]{}
+	for i, v := range []int64{5, 3, 8, 1, 9, 2, 7} {
+		h.Push(&benchItem{id: uint64(i + 1), v: v})
+	}
+	if item := h.Remove(3); item == nil || item.Value() != 8 {
+		t.Fatalf("remove key 3 got %v", item)
+	}
+	if item := h.Remove(3); item != nil {
+		t.Fatal("remove missing key returned item")
+	}
+	if item := h.RemoveByIndex(h.Len()); item != nil {
+		t.Fatal("remove out of range returned item")
+	}
+	checkHeap(t, h)
+	var got []int64
+	for h.Len() > 0 {
+		got = append(got, h.Pop().Value())
+	}
+	want := []int64{1, 2, 3, 5, 7, 9}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("pop order %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package algorithm
+
+import (
+	"fmt"
+)
+
+// 拓扑排序并分层
+// deps[k]为节点k依赖的节点列表
+// 返回的每一层节点只依赖之前层的节点, 同层节点之间互不依赖
+// 同一层内保持nodes中的原始顺序
+func TopoLayers[K comparable](nodes []K, deps map[K][]K) ([][]K, error) {
+	indegree := make(map[K]int, len(nodes))
+	for _, n := range nodes {
+		indegree[n] = 0
+	}
+	dependents := make(map[K][]K, len(nodes))
+	for _, n := range nodes {
+		for _, d := range deps[n] {
+			if _, ok := indegree[d]; !ok {
+				return nil, fmt.Errorf("%v depends on unknown node %v", n, d)
+			}
+			indegree[n]++
+			dependents[d] = append(dependents[d], n)
+		}
+	}
+	var layers [][]K
+	var current []K
+	for _, n := range nodes {
+		if indegree[n] == 0 {
+			current = append(current, n)
+		}
+	}
+	sorted := 0
+	for len(current) > 0 {
+		layers = append(layers, current)
+		sorted += len(current)
+		next := Set[K]{}
+		for _, n := range current {
+			for _, d := range dependents[n] {
+				indegree[d]--
+				if indegree[d] == 0 {
+					next.Insert(d)
+				}
+			}
+		}
+		current = nil
+		for _, n := range nodes {
+			if next.Find(n) {
+				current = append(current, n)
+			}
+		}
+	}
+	if sorted != len(nodes) {
+		var cycle []K
+		for _, n := range nodes {
+			if indegree[n] > 0 {
+				cycle = append(cycle, n)
+			}
+		}
+		return nil, fmt.Errorf("dependency cycle detected %v", cycle)
+	}
+	return layers, nil
+}
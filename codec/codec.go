@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sync"
 
 	"github.com/tnnmigga/core/infra/zlog"
 	"github.com/tnnmigga/core/utils"
@@ -15,6 +16,7 @@ import (
 
 var (
 	msgIDToDesc map[uint32]*MessageDescriptor
+	rw          sync.RWMutex // 模块可在运行时注册消息
 )
 
 func init() {
@@ -46,6 +48,8 @@ func Register[T any](t ...T) {
 	v := t[0]
 	name := utils.TypeName(v)
 	id := utils.TypeID(v)
+	rw.Lock()
+	defer rw.Unlock()
 	if desc, has := msgIDToDesc[id]; has {
 		if desc.MessageName != name {
 			zlog.Panicf("msgid duplicat %v %d", name, id)
@@ -62,6 +66,29 @@ func Register[T any](t ...T) {
 	}
 }
 
+// 按消息名查找已注册的消息
+func FindByName(name string) (*MessageDescriptor, bool) {
+	rw.RLock()
+	defer rw.RUnlock()
+	for _, desc := range msgIDToDesc {
+		if desc.MessageName == name {
+			return desc, true
+		}
+	}
+	return nil, false
+}
+
+// 所有已注册的消息, key为消息id
+func Descriptors() map[uint32]*MessageDescriptor {
+	rw.RLock()
+	defer rw.RUnlock()
+	res := make(map[uint32]*MessageDescriptor, len(msgIDToDesc))
+	for id, desc := range msgIDToDesc {
+		res[id] = desc
+	}
+	return res
+}
+
 // 编码
 // 额外拼接四字节类型id
 func Encode(v any) []byte {
@@ -81,7 +108,9 @@ func Decode(b []byte) (msg any, err error) {
 		return nil, fmt.Errorf("message decode len error %d", len(b))
 	}
 	msgID := binary.LittleEndian.Uint32(b)
+	rw.RLock()
 	desc, ok := msgIDToDesc[msgID]
+	rw.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("message decode msgid not found %d", msgID)
 	}
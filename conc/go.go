@@ -3,6 +3,7 @@ package conc
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tnnmigga/core/algorithm"
@@ -50,8 +51,7 @@ func (wkg *workerGroup) run(name string, fn func()) {
 	} else {
 		w = value.(*worker)
 	}
-	w.count++
-	pending := w.count
+	pending := w.count.Add(1)
 	wkg.mu.Unlock()
 	w.pending <- fn
 	if pending == 1 {
@@ -62,7 +62,7 @@ func (wkg *workerGroup) run(name string, fn func()) {
 type worker struct {
 	name    string
 	pending chan func()
-	count   int32
+	count   atomic.Int32
 }
 
 func (w *worker) work() {
@@ -70,11 +70,11 @@ func (w *worker) work() {
 		select {
 		case fn := <-w.pending:
 			utils.ExecAndRecover(fn)
-			w.count--
+			w.count.Add(-1)
 		default:
 			wkg.mu.Lock()
 			var empty bool
-			if w.count == 0 {
+			if w.count.Load() == 0 {
 				wkg.group.Delete(w.name)
 				wkg.workerPool.Put(w)
 				empty = true
@@ -119,6 +119,26 @@ func GoWithGroup(name string, fn func()) {
 	wkg.run(name, fn)
 }
 
+// 当前所有分组及其尚未执行完的任务数量
+func Groups() map[string]int {
+	res := map[string]int{}
+	wkg.group.Range(func(key, value any) bool {
+		res[key.(string)] = int(value.(*worker).count.Load())
+		return true
+	})
+	return res
+}
+
+// 所有分组中尚未执行完的任务数量
+func GroupPending() int {
+	var total int
+	wkg.group.Range(func(_, value any) bool {
+		total += int(value.(*worker).count.Load())
+		return true
+	})
+	return total
+}
+
 // 等候所有由Go开辟的协程退出
 func WaitGoDone(maxWaitTime time.Duration) {
 	cancelGo()
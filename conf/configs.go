@@ -36,8 +36,9 @@ func loadLocalFile(fname string) []byte {
 }
 
 var (
-	confs map[string]any = map[string]any{}
-	fns   []func() error
+	confs   map[string]any = map[string]any{}
+	fns     []func() error
+	onLoads []func([]byte)
 )
 
 var errConfigNotFound error = errors.New("configs not found")
@@ -48,6 +49,15 @@ func LoadFromJSON(b []byte) {
 	if err != nil {
 		panic(fmt.Errorf("LoadFromJSON unmarshal error %v", err))
 	}
+	for _, fn := range onLoads {
+		fn(b)
+	}
+}
+
+// 注册配置加载后的回调
+// 只对注册之后的加载生效, 可用于记录配置重载
+func OnLoad(fn func(b []byte)) {
+	onLoads = append(onLoads, fn)
 }
 
 func uncomment(b []byte) []byte {
@@ -81,6 +91,19 @@ func afterLoad() error {
 	return nil
 }
 
+// 当前生效的全部配置(拷贝)
+func All() map[string]any {
+	b, err := json.Marshal(confs)
+	if err != nil {
+		panic(err)
+	}
+	res := map[string]any{}
+	if err := json.Unmarshal(b, &res); err != nil {
+		panic(err)
+	}
+	return res
+}
+
 type vType interface {
 	float64 | bool | string | map[string]any | []any
 }
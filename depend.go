@@ -0,0 +1,93 @@
+package nett
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/tnnmigga/core/algorithm"
+	"github.com/tnnmigga/core/idef"
+)
+
+// 模块声明的依赖
+// 除link外所有模块都隐式依赖link, 保证nats最先启动最后停止
+func moduleDepends(m idef.IModule) []idef.ModName {
+	var depends []idef.ModName
+	if m.Name() != idef.ModLink {
+		depends = append(depends, idef.ModLink)
+	}
+	if d, ok := m.(idef.IDependent); ok {
+		for _, name := range d.Depends() {
+			if name != idef.ModLink {
+				depends = append(depends, name)
+			}
+		}
+	}
+	return depends
+}
+
+// 按依赖关系对模块分层
+// 依赖不存在或存在循环依赖时返回错误
+func sortModules(modules []idef.IModule) ([][]idef.IModule, error) {
+	names := make([]idef.ModName, 0, len(modules))
+	byName := make(map[idef.ModName]idef.IModule, len(modules))
+	deps := make(map[idef.ModName][]idef.ModName, len(modules))
+	for _, m := range modules {
+		if _, ok := byName[m.Name()]; ok {
+			return nil, fmt.Errorf("module %s duplicate", m.Name())
+		}
+		names = append(names, m.Name())
+		byName[m.Name()] = m
+		deps[m.Name()] = moduleDepends(m)
+	}
+	nameLayers, err := algorithm.TopoLayers(names, deps)
+	if err != nil {
+		return nil, err
+	}
+	layers := make([][]idef.IModule, 0, len(nameLayers))
+	for _, nl := range nameLayers {
+		layer := make([]idef.IModule, 0, len(nl))
+		for _, name := range nl {
+			layer = append(layer, byName[name])
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+// 查找依赖指定模块的其他模块
+func dependents(modules []idef.IModule, name idef.ModName) []idef.ModName {
+	var res []idef.ModName
+	for _, m := range modules {
+		for _, d := range moduleDepends(m) {
+			if d == name {
+				res = append(res, m.Name())
+				break
+			}
+		}
+	}
+	return res
+}
+
+// 按层执行 同一层的模块并行执行
+// reverse为true时从依赖方开始倒序执行
+func (s *Server) eachLayer(reverse bool, fn func(m idef.IModule)) {
+	for i := range s.layers {
+		layer := s.layers[i]
+		if reverse {
+			layer = s.layers[len(s.layers)-1-i]
+		}
+		if len(layer) == 1 {
+			fn(layer[0])
+			continue
+		}
+		wg := &sync.WaitGroup{}
+		for _, m := range layer {
+			wg.Add(1)
+			go func(m idef.IModule) {
+				defer wg.Done()
+				fn(m)
+			}(m)
+		}
+		wg.Wait()
+	}
+}
@@ -0,0 +1,67 @@
+package nett
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tnnmigga/core/conc"
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/msgbus"
+)
+
+// 停止所有模块的外部输入
+func (s *Server) closeIntake() {
+	for _, m := range s.modules {
+		if c, ok := m.(idef.IIntakeCloser); ok {
+			if err := wrapHook(c.CloseIntake)(); err != nil {
+				zlog.Errorf("module %s close intake error %v", m.Name(), err)
+			}
+		}
+	}
+}
+
+// 等待模块消息/异步调用/分组任务/RPC全部完成
+// global为true时同时等待进程级的分组任务和RPC
+// 超时后输出仍未完成的工作
+func (s *Server) drain(maxWaitTime time.Duration, global bool, modules ...idef.IModule) {
+	deadline := time.Now().Add(maxWaitTime)
+	for {
+		remain := outstanding(global, modules)
+		if len(remain) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			zlog.Errorf("drain timeout, outstanding %v", remain)
+			return
+		}
+		// 每100ms检查一次
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// 统计尚未完成的工作, 只返回非零项
+func outstanding(global bool, modules []idef.IModule) map[string]int {
+	remain := map[string]int{}
+	for _, m := range modules {
+		counts := map[string]int{"mq": len(m.MQ())}
+		if i, ok := m.(idef.IInflight); ok {
+			counts = i.Inflight()
+		}
+		for k, n := range counts {
+			if n > 0 {
+				remain[fmt.Sprintf("%s.%s", m.Name(), k)] = n
+			}
+		}
+	}
+	if !global {
+		return remain
+	}
+	if n := conc.GroupPending(); n > 0 {
+		remain["conc.group"] = n
+	}
+	if n := msgbus.PendingRPC(); n > 0 {
+		remain["msgbus.rpc"] = n
+	}
+	return remain
+}
@@ -0,0 +1,40 @@
+package nett
+
+import (
+	"sync"
+
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/cluster"
+)
+
+// 汇总集群节点和各模块的健康状态
+// 未实现idef.IHealthChecker的模块不参与检查
+func (s *Server) Health() map[string]error {
+	res := map[string]error{
+		"cluster": cluster.HealthCheck(),
+	}
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, m := range s.Modules() {
+		checker, ok := m.(idef.IHealthChecker)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(name idef.ModName) {
+			defer wg.Done()
+			err := wrapHook(checker.HealthCheck)()
+			mu.Lock()
+			res[string(name)] = err
+			mu.Unlock()
+		}(m.Name())
+	}
+	wg.Wait()
+	return res
+}
+
+// 服务是否可以接收流量
+// 运行阶段之后为true, 进入停止阶段立即变为false
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
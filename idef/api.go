@@ -8,6 +8,8 @@ type IModule interface {
 	Name() ModName
 	// 将一个消息指派给这个模块处理
 	Assign(any)
+	// 指派消息 队列已满或模块已停止时丢弃并返回false
+	TryAssign(any) bool
 	// 消息缓冲chan
 	MQ() chan any
 	// 开始消息处理
@@ -28,3 +30,33 @@ type IModule interface {
 	// 匿名函数捕获的变量需要防范并发读写问题
 	Async(f func() (any, error), cb func(any, error))
 }
+
+// 可选接口 模块健康检查
+// 返回nil表示模块及其依赖的外部服务正常
+// 会在模块协程外调用, 实现时需注意并发安全
+type IHealthChecker interface {
+	HealthCheck() error
+}
+
+// 可选接口 统计模块尚未完成的工作
+// 停止流程中会等待所有计数归零
+type IInflight interface {
+	Inflight() map[string]int
+}
+
+// 可选接口 停止接收外部输入
+// 停止流程开始时最先调用, 需要支持重复调用
+type IIntakeCloser interface {
+	CloseIntake() error
+}
+
+// 可选接口 消息实现后其RPC调用会写入审计日志
+type IAudited interface {
+	Audited()
+}
+
+// 可选接口 声明模块依赖的其他模块
+// 被依赖的模块先初始化/运行, 后停止
+type IDependent interface {
+	Depends() []ModName
+}
@@ -0,0 +1,344 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tnnmigga/core/conc"
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/msgbus"
+	"github.com/tnnmigga/core/utils"
+	"github.com/tnnmigga/core/utils/clock"
+)
+
+var ErrFlushTimeout = errors.New("cache flush timeout")
+
+// 已注册cacheTask处理函数的模块实例 模块停止时移除, 同名模块重新添加后可再次注册
+var (
+	registered   = map[idef.IModule]bool{}
+	registeredMu sync.Mutex
+)
+
+// 在模块协程执行的任务 同一模块的多个缓存共用
+type cacheTask struct {
+	fn func()
+}
+
+func onCacheTask(t *cacheTask) {
+	t.fn()
+}
+
+type entry[V any] struct {
+	value   *V
+	dirty   map[string]struct{} // 脏字段
+	all     bool                // 整体脏
+	saving  int                 // 正在写回的次数
+	access  time.Duration       // 最后访问时间
+	loading bool
+	waiters []func(*V, error)
+}
+
+func (e *entry[V]) isDirty() bool {
+	return e.all || len(e.dirty) > 0
+}
+
+// 回写缓存
+// 未命中时从存储加载, 修改后标记脏字段, 定期按key合并写回, 长时间未访问的数据写回后淘汰
+// 停止阶段之前在模块协程中写回全部脏数据, 模块需要依赖存储模块以保证其后停止
+// 除构造外所有方法只能在所属模块协程中调用
+type Cache[K comparable, V any] struct {
+	module   idef.IModule
+	store    IStore[K, V]
+	entries  map[K]*entry[V]
+	interval time.Duration // 写回间隔
+	idle     time.Duration // 淘汰时长 0表示不淘汰
+	stopped  bool
+	unsaved  map[K]struct{} // 未写回成功的key 停止时写回超时用于输出丢失的数据
+	mu       sync.Mutex     // 保护unsaved 停止流程在模块协程外读取
+}
+
+type Option func(*options)
+
+type options struct {
+	interval time.Duration
+	idle     time.Duration
+}
+
+// 脏数据写回间隔 默认5秒
+func WithFlushInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.interval = d
+	}
+}
+
+// 超过此时长未访问的数据写回后淘汰 默认30分钟, 0表示不淘汰
+func WithIdle(d time.Duration) Option {
+	return func(o *options) {
+		o.idle = d
+	}
+}
+
+func New[K comparable, V any](m idef.IModule, store IStore[K, V], opts ...Option) *Cache[K, V] {
+	o := &options{
+		interval: 5 * time.Second,
+		idle:     30 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	c := &Cache[K, V]{
+		module:   m,
+		store:    store,
+		entries:  map[K]*entry[V]{},
+		interval: o.interval,
+		idle:     o.idle,
+		unsaved:  map[K]struct{}{},
+	}
+	registeredMu.Lock()
+	if !registered[m] {
+		registered[m] = true
+		msgbus.RegisterHandler(m, onCacheTask)
+		m.After(idef.ServerStateStop, func() error {
+			registeredMu.Lock()
+			defer registeredMu.Unlock()
+			delete(registered, m)
+			return nil
+		})
+	}
+	registeredMu.Unlock()
+	m.After(idef.ServerStateRun, c.afterRun)
+	m.Before(idef.ServerStateStop, c.beforeStop)
+	return c
+}
+
+func (c *Cache[K, V]) afterRun() error {
+	conc.Go(func(ctx context.Context) {
+		ticker := clock.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				c.module.Assign(&cacheTask{fn: c.tick})
+			}
+		}
+	})
+	return nil
+}
+
+// 在模块协程中写回全部脏数据 写回请求发出后返回, 由退出流程等待RPC完成
+// 模块队列已满时重试投递, 超时后输出未写回的key
+func (c *Cache[K, V]) beforeStop() error {
+	done := make(chan struct{})
+	task := &cacheTask{fn: func() {
+		c.stopped = true
+		c.Flush()
+		close(done)
+	}}
+	timer := time.NewTimer(10 * time.Second)
+	defer timer.Stop()
+	for !c.module.TryAssign(task) {
+		select {
+		case <-timer.C:
+			return c.flushTimeout()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return c.flushTimeout()
+	}
+}
+
+func (c *Cache[K, V]) flushTimeout() error {
+	c.mu.Lock()
+	keys := make([]K, 0, len(c.unsaved))
+	for key := range c.unsaved {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+	zlog.Errorf("cache %s flush timeout, %d keys lost %v", c.module.Name(), len(keys), keys)
+	return fmt.Errorf("%w: %d keys lost", ErrFlushTimeout, len(keys))
+}
+
+// 标记key存在未写回的修改
+func (c *Cache[K, V]) markUnsaved(key K) {
+	c.mu.Lock()
+	c.unsaved[key] = struct{}{}
+	c.mu.Unlock()
+}
+
+// 获取数据 命中时同步回调, 未命中时加载后回调, 不存在时回调nil, nil
+func (c *Cache[K, V]) Get(key K, cb func(*V, error)) {
+	e, ok := c.entries[key]
+	if ok && !e.loading {
+		e.access = utils.NowNs()
+		cb(e.value, nil)
+		return
+	}
+	if ok {
+		e.waiters = append(e.waiters, cb)
+		return
+	}
+	e = &entry[V]{loading: true, waiters: []func(*V, error){cb}}
+	c.entries[key] = e
+	c.store.Load(c.module, key, func(v *V, err error) {
+		waiters := e.waiters
+		e.waiters = nil
+		e.loading = false
+		if e.value != nil {
+			// 加载期间被Put覆盖或写回失败的数据转入 以内存中的值为准
+			err = nil
+		} else if err != nil || v == nil {
+			if c.entries[key] == e {
+				delete(c.entries, key)
+			}
+		} else {
+			e.value = v
+			e.access = utils.NowNs()
+		}
+		for _, w := range waiters {
+			w(utils.IfElse(err == nil, e.value, nil), err)
+		}
+	})
+}
+
+// 只查询已缓存的数据
+func (c *Cache[K, V]) Peek(key K) (*V, bool) {
+	e, ok := c.entries[key]
+	if !ok || e.loading {
+		return nil, false
+	}
+	e.access = utils.NowNs()
+	return e.value, true
+}
+
+// 放入数据并标记为整体脏
+func (c *Cache[K, V]) Put(key K, v *V) {
+	e, ok := c.entries[key]
+	if !ok {
+		e = &entry[V]{}
+		c.entries[key] = e
+	}
+	e.value = v
+	e.all = true
+	e.access = utils.NowNs()
+	c.markUnsaved(key)
+}
+
+// 标记脏字段 字段为bson名, 不传表示整体脏
+func (c *Cache[K, V]) MarkDirty(key K, fields ...string) {
+	e, ok := c.entries[key]
+	if !ok || e.value == nil {
+		zlog.Errorf("cache mark dirty not found %v", key)
+		return
+	}
+	e.access = utils.NowNs()
+	c.markUnsaved(key)
+	if len(fields) == 0 {
+		e.all = true
+		return
+	}
+	if e.dirty == nil {
+		e.dirty = map[string]struct{}{}
+	}
+	for _, f := range fields {
+		e.dirty[f] = struct{}{}
+	}
+}
+
+// 写回并移除
+func (c *Cache[K, V]) Evict(key K) {
+	e, ok := c.entries[key]
+	if !ok || e.loading {
+		return
+	}
+	c.flush(key, e)
+	delete(c.entries, key)
+}
+
+// 立即写回全部脏数据
+func (c *Cache[K, V]) Flush() {
+	for key, e := range c.entries {
+		c.flush(key, e)
+	}
+}
+
+func (c *Cache[K, V]) Len() int {
+	return len(c.entries)
+}
+
+func (c *Cache[K, V]) flush(key K, e *entry[V]) {
+	if e.loading || e.value == nil || !e.isDirty() {
+		return
+	}
+	var fields []string
+	if !e.all {
+		fields = make([]string, 0, len(e.dirty))
+		for f := range e.dirty {
+			fields = append(fields, f)
+		}
+	}
+	e.all = false
+	e.dirty = nil
+	e.saving++
+	c.store.Save(c.module, key, e.value, fields, func(err error) {
+		e.saving--
+		if err == nil {
+			// 淘汰后可能已重新加载 以当前的条目为准
+			cur, ok := c.entries[key]
+			if !ok {
+				cur = e
+			}
+			if cur.saving == 0 && !cur.isDirty() {
+				c.mu.Lock()
+				delete(c.unsaved, key)
+				c.mu.Unlock()
+			}
+			return
+		}
+		zlog.Errorf("cache save %v error %v", key, err)
+		// 写回失败重新标记 等待下次写回
+		e.all = true
+		cur, ok := c.entries[key]
+		switch {
+		case !ok:
+			if !c.stopped {
+				c.entries[key] = e
+			}
+		case cur == e:
+		case cur.loading && cur.value == nil:
+			// 淘汰后正在重新加载 存储中的数据缺少本次修改, 转入新条目 加载完成后沿用
+			cur.value = e.value
+			cur.all = true
+			cur.access = e.access
+		default:
+			// 淘汰后已重新加载并可能被修改 无法合并
+			zlog.Errorf("cache save %v failed after reload, changes lost", key)
+		}
+	})
+}
+
+// 定期写回并淘汰空闲数据
+func (c *Cache[K, V]) tick() {
+	if c.stopped {
+		return
+	}
+	nowNs := utils.NowNs()
+	for key, e := range c.entries {
+		if e.loading {
+			continue
+		}
+		c.flush(key, e)
+		if c.idle > 0 && e.saving == 0 && nowNs-e.access > c.idle {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/mods/mongo"
+	"github.com/tnnmigga/core/mods/redis"
+	"github.com/tnnmigga/core/msgbus"
+
+	goredis "github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 缓存的存储后端
+// 所有方法都在模块协程中调用, 回调也由模块协程执行
+type IStore[K comparable, V any] interface {
+	// 加载数据 不存在时回调nil, nil
+	Load(caller idef.IModule, key K, cb func(*V, error))
+	// 写回数据 fields为脏字段的bson名, 为空表示整体写回
+	Save(caller idef.IModule, key K, v *V, fields []string, cb func(error))
+}
+
+// 通过mongo模块读写 文档_id为key
+type MongoStore[K comparable, V any] struct {
+	repo *mongo.Repository[V]
+}
+
+func NewMongoStore[K comparable, V any](caller idef.IModule, collName string) *MongoStore[K, V] {
+	return &MongoStore[K, V]{
+		repo: mongo.NewRepository[V](caller, collName),
+	}
+}
+
+func (s *MongoStore[K, V]) Load(caller idef.IModule, key K, cb func(*V, error)) {
+	s.repo.Load(bson.M{"_id": key}, func(v *V, err error) {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			cb(nil, nil)
+			return
+		}
+		cb(v, err)
+	})
+}
+
+// 有脏字段时只$set这些字段, 否则整体替换
+// 文档不存在时$set不插入, 改为整体替换 避免只有部分字段的文档
+func (s *MongoStore[K, V]) Save(caller idef.IModule, key K, v *V, fields []string, cb func(error)) {
+	if len(fields) == 0 {
+		s.repo.Upsert(bson.M{"_id": key}, v, cb)
+		return
+	}
+	b, err := bson.Marshal(v)
+	if err != nil {
+		cb(err)
+		return
+	}
+	set := bson.M{}
+	for _, f := range fields {
+		value, err := bson.Raw(b).LookupErr(strings.Split(f, ".")...)
+		if err != nil {
+			// 字段被置空时bson中可能不存在 整体替换
+			s.repo.Upsert(bson.M{"_id": key}, v, cb)
+			return
+		}
+		set[f] = value
+	}
+	s.repo.Update(bson.M{"_id": key}, bson.M{"$set": set}, false, func(res *mongo.MongoResult, err error) {
+		if err == nil && res.MatchedCount == 0 {
+			s.repo.Upsert(bson.M{"_id": key}, v, cb)
+			return
+		}
+		cb(err)
+	})
+}
+
+// 通过redis模块读写 数据以bson保存在 Prefix:key
+type RedisStore[K comparable, V any] struct {
+	Prefix string
+}
+
+func (s *RedisStore[K, V]) key(key K) string {
+	return fmt.Sprintf("%s:%v", s.Prefix, key)
+}
+
+func (s *RedisStore[K, V]) Load(caller idef.IModule, key K, cb func(*V, error)) {
+	req := &redis.Exec{
+		Cmd: []any{"GET", s.key(key)},
+	}
+	msgbus.RPC(caller, msgbus.Local(), req, func(res any, err error) {
+		if errors.Is(err, goredis.Nil) {
+			cb(nil, nil)
+			return
+		}
+		if err != nil {
+			cb(nil, err)
+			return
+		}
+		str, _ := res.(string)
+		v := new(V)
+		if err := bson.Unmarshal([]byte(str), v); err != nil {
+			cb(nil, err)
+			return
+		}
+		cb(v, nil)
+	})
+}
+
+func (s *RedisStore[K, V]) Save(caller idef.IModule, key K, v *V, fields []string, cb func(error)) {
+	b, err := bson.Marshal(v)
+	if err != nil {
+		cb(err)
+		return
+	}
+	req := &redis.Exec{
+		Cmd: []any{"SET", s.key(key), b},
+	}
+	msgbus.RPC(caller, msgbus.Local(), req, func(_ any, err error) {
+		cb(err)
+	})
+}
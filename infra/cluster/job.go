@@ -0,0 +1,242 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/utils/idgen"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobClaimed  = errors.New("job claimed by other node")
+)
+
+const (
+	jobPrefix   = "/cluster/jobs"       // 任务数据 按id索引
+	duePrefix   = "/cluster/job-due"    // 到期索引 按到期时间排序
+	claimPrefix = "/cluster/job-claims" // 认领记录 绑定认领节点的租约
+)
+
+// 集群任务
+// 存储在etcd中, 任意进程均可认领投递, 进程退出后由其他进程接管
+type Job struct {
+	ID         string
+	At         int64  // 到期时间 纳秒 重试时后移
+	FireAt     int64  // 首次到期时间 纳秒 重试不变
+	ServerType string // 投递的目标进程类型
+	HashKey    string // 不为空时按哈希选择目标进程, 否则随机
+	Data       []byte // codec编码后的消息
+	Attempts   int    // 已失败的投递次数
+	claim      string // 当前节点的认领记录 释放和重试时校验
+}
+
+// 认领记录
+// 认领后未在Deadline前完成(如投递的响应丢失)时视为过期, 由轮询删除后重新认领
+type jobClaim struct {
+	Node     string
+	Deadline int64 // 纳秒
+}
+
+func etcdJobKey(id string) string {
+	return fmt.Sprintf("%s/%s", jobPrefix, id)
+}
+
+func etcdDueKey(at int64, id string) string {
+	// 定长时间保证按字典序即按时间排序
+	return fmt.Sprintf("%s/%020d/%s", duePrefix, at, id)
+}
+
+func etcdClaimKey(id string) string {
+	return fmt.Sprintf("%s/%s", claimPrefix, id)
+}
+
+// 保存任务 ID为空时自动生成
+func PutJob(job *Job) error {
+	if job.ID == "" {
+		id, err := idgen.Next()
+		if err != nil {
+			return err
+		}
+		job.ID = strconv.FormatUint(id, 10)
+	}
+	if job.FireAt == 0 {
+		job.FireAt = job.At
+	}
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	_, err = etcd.Txn(ctx).Then(
+		clientv3.OpPut(etcdJobKey(job.ID), string(b)),
+		clientv3.OpPut(etcdDueKey(job.At, job.ID), job.ID),
+	).Commit()
+	return err
+}
+
+// 读取任务
+func GetJob(id string) (*Job, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	resp, err := etcd.Get(ctx, etcdJobKey(id))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, ErrJobNotFound
+	}
+	job := &Job{}
+	err = json.Unmarshal(resp.Kvs[0].Value, job)
+	return job, err
+}
+
+// 删除任务及其索引和认领记录
+func DeleteJob(id string) error {
+	job, err := GetJob(id)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	_, err = etcd.Txn(ctx).Then(
+		clientv3.OpDelete(etcdJobKey(id)),
+		clientv3.OpDelete(etcdDueKey(job.At, id)),
+		clientv3.OpDelete(etcdClaimKey(id)),
+	).Commit()
+	return err
+}
+
+// 查询已到期且未被认领的任务id 按到期时间升序, 最多limit个
+// 认领已过期的任务删除认领记录后视为未认领
+// 认领中的任务不超过认领记录数, 多取相应数量的到期索引保证过滤后仍能取满
+func DueJobs(now time.Time, limit int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	claims, err := etcd.Get(ctx, claimPrefix+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	claimed := make(map[string]bool, len(claims.Kvs))
+	for _, kv := range claims.Kvs {
+		id := strings.TrimPrefix(string(kv.Key), claimPrefix+"/")
+		claim := &jobClaim{}
+		if json.Unmarshal(kv.Value, claim) == nil && claim.Deadline <= now.UnixNano() {
+			// 只删除读取到的这条认领 期间被重新认领时保留
+			resp, err := etcd.Txn(ctx).
+				If(clientv3.Compare(clientv3.ModRevision(string(kv.Key)), "=", kv.ModRevision)).
+				Then(clientv3.OpDelete(string(kv.Key))).
+				Commit()
+			if err == nil && resp.Succeeded {
+				zlog.Warnf("cluster job %s claim by %s expired", id, claim.Node)
+				continue
+			}
+		}
+		claimed[id] = true
+	}
+	end := fmt.Sprintf("%s/%020d", duePrefix, now.UnixNano()+1)
+	resp, err := etcd.Get(ctx, duePrefix+"/", clientv3.WithRange(end), clientv3.WithLimit(int64(limit+len(claimed))))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		id := string(kv.Value)
+		if claimed[id] {
+			continue
+		}
+		ids = append(ids, id)
+		if len(ids) == limit {
+			break
+		}
+	}
+	return ids, nil
+}
+
+// 认领任务 需要在deadline前完成投递并删除/重试/释放, 否则认领过期后任务被重新认领
+// 认领记录绑定当前节点的租约, 节点退出后自动释放由其他节点重新认领
+func ClaimJob(id string, deadline time.Time) (*Job, error) {
+	if clusterNode == nil {
+		return nil, ErrNodeNotAlive
+	}
+	b, err := json.Marshal(&jobClaim{Node: etcdNodeKey(), Deadline: deadline.UnixNano()})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	key := etcdClaimKey(id)
+	resp, err := etcd.Txn(ctx).
+		If(clientv3.Compare(clientv3.Version(etcdJobKey(id)), ">", 0), clientv3.Compare(clientv3.Version(key), "=", 0)).
+		Then(clientv3.OpPut(key, string(b), clientv3.WithLease(clusterNode.leaseID)), clientv3.OpGet(etcdJobKey(id))).
+		Commit()
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Succeeded {
+		return nil, ErrJobClaimed
+	}
+	kvs := resp.Responses[1].GetResponseRange().Kvs
+	if len(kvs) == 0 {
+		return nil, ErrJobNotFound
+	}
+	job := &Job{claim: string(b)}
+	err = json.Unmarshal(kvs[0].Value, job)
+	return job, err
+}
+
+// 释放当前节点的认领 任务保持到期状态, 由下次轮询重新认领
+// 认领已过期被其他节点接管时不做修改
+func ReleaseJob(job *Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	key := etcdClaimKey(job.ID)
+	_, err := etcd.Txn(ctx).
+		If(clientv3.Compare(clientv3.Value(key), "=", job.claim)).
+		Then(clientv3.OpDelete(key)).
+		Commit()
+	return err
+}
+
+// 投递失败后延后到at重试, 同时释放认领
+// 认领已过期被其他节点接管时返回ErrJobClaimed
+func RetryJob(job *Job, at time.Time) error {
+	old := job.At
+	job.At = at.UnixNano()
+	job.Attempts++
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	resp, err := etcd.Txn(ctx).
+		If(
+			clientv3.Compare(clientv3.Version(etcdJobKey(job.ID)), ">", 0),
+			clientv3.Compare(clientv3.Value(etcdClaimKey(job.ID)), "=", job.claim),
+		).
+		Then(
+			clientv3.OpPut(etcdJobKey(job.ID), string(b)),
+			clientv3.OpDelete(etcdDueKey(old, job.ID)),
+			clientv3.OpPut(etcdDueKey(job.At, job.ID), job.ID),
+			clientv3.OpDelete(etcdClaimKey(job.ID)),
+		).Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		if _, err := GetJob(job.ID); err != nil {
+			return err // 重试前已被取消
+		}
+		return ErrJobClaimed
+	}
+	return nil
+}
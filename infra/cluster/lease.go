@@ -47,6 +47,7 @@ func (n *Lease) keepAlive() {
 				zlog.Errorf("%v: %s", r, debug.Stack())
 			}
 		}()
+		// etcd按真实时间判断租约过期 不使用可替换的clock
 		ticker := time.NewTicker(leaseTTL * time.Second / 2)
 		defer ticker.Stop()
 		for {
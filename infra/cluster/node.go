@@ -4,7 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/tnnmigga/core/conf"
@@ -17,6 +21,7 @@ import (
 
 var (
 	ErrNodeIsExists = errors.New("node already exists")
+	ErrNodeNotAlive = errors.New("node lease not alive")
 )
 
 var etcd *clientv3.Client
@@ -37,6 +42,7 @@ type Node struct {
 	waitLocks *waitLockManager
 	leaseID   clientv3.LeaseID
 	cancelCtx utils.IContextWithCancel
+	alive     atomic.Bool // 租约是否正常续期
 }
 
 func Init() error {
@@ -56,7 +62,7 @@ func Init() error {
 	}
 	putTxn := etcd.Txn(ctx)
 	putTxn.If(clientv3.Compare(clientv3.Version(etcdNodeKey()), "=", 0)).
-		Then(clientv3.OpPut(etcdNodeKey(), "", clientv3.WithLease(lease.ID)))
+		Then(clientv3.OpPut(etcdNodeKey(), conf.ServerType, clientv3.WithLease(lease.ID)))
 	putRes, err := putTxn.Commit()
 	if err != nil {
 		return err
@@ -69,10 +75,42 @@ func Init() error {
 		leaseID:   lease.ID,
 		waitLocks: newWaitLockManager(ctx),
 	}
+	clusterNode.alive.Store(true)
 	clusterNode.KeepAlive()
 	return nil
 }
 
+// 检查节点租约是否存活
+func HealthCheck() error {
+	if clusterNode == nil || !clusterNode.alive.Load() {
+		return ErrNodeNotAlive
+	}
+	return nil
+}
+
+// 查询某类进程当前存活的节点 按serverID升序
+func Nodes(serverType string) ([]uint32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	resp, err := etcd.Get(ctx, nodePrefix+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]uint32, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		if string(kv.Value) != serverType {
+			continue
+		}
+		id, err := strconv.ParseUint(path.Base(string(kv.Key)), 10, 32)
+		if err != nil {
+			continue
+		}
+		nodes = append(nodes, uint32(id))
+	}
+	slices.Sort(nodes)
+	return nodes, nil
+}
+
 func etcdNodeKey() string {
 	return fmt.Sprintf("%s/%d", nodePrefix, conf.ServerID)
 }
@@ -86,6 +124,7 @@ func (n *Node) KeepAlive() {
 				process.Exit()
 			}
 		}()
+		// etcd按真实时间判断租约过期 不使用可替换的clock
 		ticker := time.NewTicker(leaseTTL * time.Second / 2)
 		defer ticker.Stop()
 		for {
@@ -99,6 +138,7 @@ func (n *Node) KeepAlive() {
 				cancel()
 				// 若etcd异常则退出
 				if err != nil && !n.cancelCtx.Canceled() {
+					n.alive.Store(false)
 					zlog.Errorf("etcd keep alive error: %v", err)
 					process.Exit()
 					return
@@ -109,6 +149,7 @@ func (n *Node) KeepAlive() {
 }
 
 func Dead() {
+	clusterNode.alive.Store(false)
 	clusterNode.cancelCtx.Cancel()
 	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
 	defer cancel()
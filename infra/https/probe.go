@@ -0,0 +1,66 @@
+package https
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 健康检查数据来源 一般由nett.Server实现
+type IProbe interface {
+	// 各组件的健康状态 nil表示正常
+	Health() map[string]error
+	// 是否可以接收流量
+	Ready() bool
+}
+
+// 注册存活/就绪探针
+// /healthz 所有组件正常时返回200
+// /readyz 服务处于运行状态且所有组件正常时返回200
+// 进入停止流程后/readyz立即返回503, 负载均衡可提前摘除流量
+func (agent *HttpAgent) RegisterProbes(p IProbe) {
+	agent.GET("/healthz", func(ctx *gin.Context) {
+		checks, ok := healthResult(p.Health())
+		ctx.JSON(statusCode(ok), gin.H{
+			"status": statusText(ok),
+			"checks": checks,
+		})
+	})
+	agent.GET("/readyz", func(ctx *gin.Context) {
+		checks, ok := healthResult(p.Health())
+		ready := p.Ready()
+		ctx.JSON(statusCode(ok && ready), gin.H{
+			"status": statusText(ok && ready),
+			"ready":  ready,
+			"checks": checks,
+		})
+	})
+}
+
+func healthResult(health map[string]error) (map[string]string, bool) {
+	ok := true
+	checks := make(map[string]string, len(health))
+	for name, err := range health {
+		if err != nil {
+			ok = false
+			checks[name] = err.Error()
+			continue
+		}
+		checks[name] = "ok"
+	}
+	return checks, ok
+}
+
+func statusCode(ok bool) int {
+	if ok {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
+
+func statusText(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "fail"
+}
@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 迁移文件 通常通过embed嵌入
+// 文件名格式 <版本>_<名称>.up<ext> 和 <版本>_<名称>.down<ext>, down文件可选
+type File struct {
+	Version uint64
+	Name    string
+	Up      []byte
+	Down    []byte // 为nil表示不可回滚
+}
+
+// 读取dir目录下扩展名为ext的迁移文件 按版本排序
+func ReadFiles(fsys fs.FS, dir string, ext string) ([]*File, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	files := map[uint64]*File{}
+	var versions []uint64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ext) {
+			continue
+		}
+		base := strings.TrimSuffix(entry.Name(), ext)
+		down := strings.HasSuffix(base, ".down")
+		if !down && !strings.HasSuffix(base, ".up") {
+			return nil, fmt.Errorf("migrate file %s should end with .up%s or .down%s", entry.Name(), ext, ext)
+		}
+		base = base[:strings.LastIndexByte(base, '.')]
+		ver, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseUint(ver, 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migrate file %s invalid version", entry.Name())
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		f, ok := files[version]
+		if !ok {
+			f = &File{Version: version, Name: name}
+			files[version] = f
+			versions = append(versions, version)
+		} else if f.Name != name {
+			return nil, fmt.Errorf("%w: %d %s %s", ErrDuplicateVersion, version, f.Name, name)
+		}
+		if down {
+			f.Down = b
+		} else {
+			f.Up = b
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	res := make([]*File, 0, len(versions))
+	for _, v := range versions {
+		if files[v].Up == nil {
+			return nil, fmt.Errorf("migrate version %d missing up file", v)
+		}
+		res = append(res, files[v])
+	}
+	return res, nil
+}
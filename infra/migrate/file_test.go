@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadFiles(t *testing.T) {
+	cases := []struct {
+		name  string
+		files map[string]string
+		want  []*File
+		err   bool
+	}{
+		{
+			name: "sorted by version",
+			files: map[string]string{
+				"10_c.up.sql":    "c",
+				"2_b.up.sql":     "b",
+				"2_b.down.sql":   "-b",
+				"1_a.up.sql":     "a",
+				"1_a.down.sql":   "-a",
+				"README.md":      "ignored",
+				"3_x.up.json":    "ignored",
+				"sub/4_d.up.sql": "ignored",
+			},
+			want: []*File{
+				{Version: 1, Name: "a", Up: []byte("a"), Down: []byte("-a")},
+				{Version: 2, Name: "b", Up: []byte("b"), Down: []byte("-b")},
+				{Version: 10, Name: "c", Up: []byte("c")},
+			},
+		},
+		{
+			name:  "only down file",
+			files: map[string]string{"1_a.up.sql": "a", "2_b.down.sql": "-b"},
+			err:   true,
+		},
+		{
+			name:  "missing direction",
+			files: map[string]string{"1_a.sql": "a"},
+			err:   true,
+		},
+		{
+			name:  "invalid version",
+			files: map[string]string{"v1_a.up.sql": "a"},
+			err:   true,
+		},
+		{
+			name:  "zero version",
+			files: map[string]string{"0_a.up.sql": "a"},
+			err:   true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for name, data := range c.files {
+				fsys["migrations/"+name] = &fstest.MapFile{Data: []byte(data)}
+			}
+			files, err := ReadFiles(fsys, "migrations", ".sql")
+			if (err != nil) != c.err {
+				t.Fatalf("err %v, want error %v", err, c.err)
+			}
+			if !reflect.DeepEqual(files, c.want) {
+				t.Fatalf("files %+v, want %+v", files, c.want)
+			}
+		})
+	}
+}
+
+func TestReadFilesDuplicate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/1_a.up.sql":   {Data: []byte("a")},
+		"m/1_b.down.sql": {Data: []byte("b")},
+	}
+	if _, err := ReadFiles(fsys, "m", ".sql"); !errors.Is(err, ErrDuplicateVersion) {
+		t.Fatalf("err %v, want %v", err, ErrDuplicateVersion)
+	}
+}
@@ -0,0 +1,149 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tnnmigga/core/conf"
+	"github.com/tnnmigga/core/infra/cluster"
+	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/utils"
+)
+
+var (
+	ErrDuplicateVersion = errors.New("migrate duplicate version")
+	ErrIrreversible     = errors.New("migrate irreversible")
+)
+
+// 单个版本的迁移 H为存储的连接类型
+// Down为nil时该版本不可回滚
+type Migration[H any] struct {
+	Version uint64
+	Name    string
+	Up      func(ctx context.Context, h H) error
+	Down    func(ctx context.Context, h H) error
+}
+
+// 已应用版本的记录
+type IStore interface {
+	Ensure(ctx context.Context) error
+	Applied(ctx context.Context) ([]uint64, error)
+	Mark(ctx context.Context, version uint64, name string) error
+	Unmark(ctx context.Context, version uint64) error
+}
+
+// 执行计划中的一步
+type Step struct {
+	Version uint64
+	Name    string
+	Down    bool
+}
+
+func (s Step) String() string {
+	return fmt.Sprintf("%s %d_%s", utils.IfElse(s.Down, "down", "up"), s.Version, s.Name)
+}
+
+type Options struct {
+	Target uint64 // 目标版本 0表示最新, 低于已应用的版本时依次回滚
+	DryRun bool   // 只输出执行计划
+}
+
+// 从配置读取选项
+// migrate.dry-run 全局生效, migrate.target.<name> 指定单个存储的目标版本
+func ConfOptions(name string) Options {
+	return Options{
+		Target: conf.Uint64("migrate.target."+name, 0),
+		DryRun: conf.Bool("migrate.dry-run", false),
+	}
+}
+
+// 在集群锁内执行迁移 同一name同时只有一个进程执行
+// 获得锁后重新读取已应用的版本, 其他进程已完成的迁移不会重复执行
+// 返回执行(或dry-run时将要执行)的步骤
+func Run[H any](name string, h H, store IStore, migrations []Migration[H], opts Options) ([]Step, error) {
+	byVersion := make(map[uint64]Migration[H], len(migrations))
+	for _, m := range migrations {
+		if _, ok := byVersion[m.Version]; ok || m.Version == 0 {
+			return nil, fmt.Errorf("%w: %s %d", ErrDuplicateVersion, name, m.Version)
+		}
+		byVersion[m.Version] = m
+	}
+	lock := cluster.NewLock("migrate/" + name)
+	if err := lock.Wait(time.Duration(conf.Int("migrate.lock-timeout", 60)) * time.Second); err != nil {
+		return nil, fmt.Errorf("migrate %s lock error %w", name, err)
+	}
+	defer lock.Release()
+	ctx := context.Background()
+	if err := store.Ensure(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := store.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := plan(byVersion, applied, opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		if opts.DryRun {
+			zlog.Infof("migrate %s dry-run %v", name, step)
+			continue
+		}
+		m := byVersion[step.Version]
+		if err := apply(ctx, h, store, m, step.Down); err != nil {
+			return nil, fmt.Errorf("migrate %s %v error %w", name, step, err)
+		}
+		zlog.Infof("migrate %s %v done", name, step)
+	}
+	return steps, nil
+}
+
+func plan[H any](byVersion map[uint64]Migration[H], applied []uint64, target uint64) ([]Step, error) {
+	done := make(map[uint64]bool, len(applied))
+	for _, v := range applied {
+		done[v] = true
+	}
+	var steps []Step
+	// 先按版本倒序回滚高于目标的版本
+	if target > 0 {
+		sort.Slice(applied, func(i, j int) bool { return applied[i] > applied[j] })
+		for _, v := range applied {
+			if v <= target {
+				break
+			}
+			m, ok := byVersion[v]
+			if !ok || m.Down == nil {
+				return nil, fmt.Errorf("%w: %d", ErrIrreversible, v)
+			}
+			steps = append(steps, Step{Version: v, Name: m.Name, Down: true})
+		}
+	}
+	versions := make([]uint64, 0, len(byVersion))
+	for v := range byVersion {
+		if !done[v] && (target == 0 || v <= target) {
+			versions = append(versions, v)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	for _, v := range versions {
+		steps = append(steps, Step{Version: v, Name: byVersion[v].Name})
+	}
+	return steps, nil
+}
+
+func apply[H any](ctx context.Context, h H, store IStore, m Migration[H], down bool) error {
+	if down {
+		if err := m.Down(ctx, h); err != nil {
+			return err
+		}
+		return store.Unmark(ctx, m.Version)
+	}
+	if err := m.Up(ctx, h); err != nil {
+		return err
+	}
+	return store.Mark(ctx, m.Version, m.Name)
+}
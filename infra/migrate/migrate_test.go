@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	noop := func(context.Context, struct{}) error { return nil }
+	migrations := map[uint64]Migration[struct{}]{
+		1: {Version: 1, Name: "a", Up: noop, Down: noop},
+		2: {Version: 2, Name: "b", Up: noop, Down: noop},
+		3: {Version: 3, Name: "c", Up: noop}, // 不可回滚
+		4: {Version: 4, Name: "d", Up: noop, Down: noop},
+	}
+	cases := []struct {
+		name    string
+		applied []uint64
+		target  uint64
+		want    []Step
+		err     error
+	}{
+		{
+			name: "fresh",
+			want: []Step{{1, "a", false}, {2, "b", false}, {3, "c", false}, {4, "d", false}},
+		},
+		{
+			name:    "partially applied",
+			applied: []uint64{1, 2},
+			want:    []Step{{3, "c", false}, {4, "d", false}},
+		},
+		{
+			name:    "up to date",
+			applied: []uint64{1, 2, 3, 4},
+		},
+		{
+			name:    "up to target",
+			applied: []uint64{1},
+			target:  2,
+			want:    []Step{{2, "b", false}},
+		},
+		{
+			name:    "down to target",
+			applied: []uint64{4, 1, 2, 3},
+			target:  3,
+			want:    []Step{{4, "d", true}},
+		},
+		{
+			// 先倒序回滚高于目标的版本 再执行目标及以下未应用的版本
+			name:    "target below every applied version",
+			applied: []uint64{2, 4},
+			target:  1,
+			want:    []Step{{4, "d", true}, {2, "b", true}, {1, "a", false}},
+		},
+		{
+			name:    "rollback across irreversible",
+			applied: []uint64{1, 2, 3, 4},
+			target:  2,
+			err:     ErrIrreversible,
+		},
+		{
+			name:    "rollback unknown version",
+			applied: []uint64{1, 5},
+			target:  1,
+			err:     ErrIrreversible,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			steps, err := plan(migrations, append([]uint64(nil), c.applied...), c.target)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("err %v, want %v", err, c.err)
+			}
+			if !reflect.DeepEqual(steps, c.want) {
+				t.Fatalf("steps %v, want %v", steps, c.want)
+			}
+		})
+	}
+}
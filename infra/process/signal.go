@@ -16,6 +16,17 @@ func WaitExitSignal() os.Signal {
 	return <-sign
 }
 
+// 监听信号并回调 回调在独立协程中执行
+func OnSignal(fn func(os.Signal), sigs ...os.Signal) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	go func() {
+		for s := range c {
+			fn(s)
+		}
+	}()
+}
+
 // 运行时故障触发进程退出流程
 func Exit() {
 	select {
@@ -1,15 +1,54 @@
 package timer
 
 import (
+	"time"
+
+	"github.com/tnnmigga/core/infra/zlog"
 	"github.com/tnnmigga/core/msgbus"
 	"github.com/tnnmigga/core/utils"
 )
 
+func (h *TimerHeap) onTimerSnapshot(req *timerSnapshot) {
+	infos := make([]*TimerInfo, 0, h.Len())
+	for _, t := range h.Items {
+		infos = append(infos, &TimerInfo{
+			ID:   t.ID,
+			Time: time.Unix(0, int64(t.Time)),
+			Ctx:  utils.String(t.Ctx),
+		})
+	}
+	req.resp <- infos
+}
+
 func (h *TimerHeap) onTimerTrigger(msg *timerTrigger) {
+	h.armed = 0
 	defer h.tryNextTrigger()
 	nowNs := utils.NowNs()
 	for top := h.Top(); top != nil && top.Time <= nowNs; top = h.Top() {
 		h.Pop()
+		if top.sched != nil {
+			h.onSchedule(top, nowNs)
+			continue
+		}
 		msgbus.Cast(top.Ctx)
+		if h.store != nil {
+			h.store.Delete(h.module, top.ID)
+		}
+	}
+}
+
+// 周期定时器触发后计算下一次触发时间并重新加入
+func (h *TimerHeap) onSchedule(t *timerCtx, nowNs time.Duration) {
+	s := t.sched
+	if late := nowNs - t.Time; late <= s.threshold || s.misfire == MisfireFireOnce {
+		msgbus.Cast(t.Ctx, msgbus.OneOfMods(h.module.Name()))
+	} else {
+		zlog.Warnf("timer schedule %d misfire skip, late %v", t.ID, late)
+	}
+	next := s.next(time.Unix(0, int64(nowNs)))
+	if next == 0 {
+		return
 	}
+	t.Time = next
+	h.Push(t)
 }
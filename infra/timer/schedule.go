@@ -0,0 +1,319 @@
+package timer
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 周期定时器错过触发时间(超过阈值)时的处理方式
+// 错过的多次触发不会逐一补发, 下一次触发时间总是从当前时间往后计算
+type MisfirePolicy int
+
+const (
+	MisfireFireOnce MisfirePolicy = iota // 立即补触发一次
+	MisfireSkip                          // 跳过本次, 等待下一次
+)
+
+type ScheduleOption func(*schedule)
+
+// 在计划时间基础上增加[0, jitter)的随机延迟, 用于打散大量同时触发的定时器
+func WithJitter(jitter time.Duration) ScheduleOption {
+	return func(s *schedule) {
+		s.jitter = jitter
+	}
+}
+
+// 设置错过触发的处理方式
+// 实际触发时间晚于计划时间超过threshold视为错过, 默认1秒
+func WithMisfire(policy MisfirePolicy, threshold time.Duration) ScheduleOption {
+	return func(s *schedule) {
+		s.misfire = policy
+		s.threshold = threshold
+	}
+}
+
+type schedule struct {
+	rule      Schedule
+	jitter    time.Duration
+	misfire   MisfirePolicy
+	threshold time.Duration
+	planned   time.Time // 不含随机延迟的计划触发时间
+}
+
+// 计算now之后的下一次触发时间(纳秒时间戳), 0表示不再触发
+func (s *schedule) next(now time.Time) time.Duration {
+	next := time.Time{}
+	if !s.planned.IsZero() {
+		next = s.rule.Next(s.planned) // 从上次计划时间往后算 避免累积误差
+	}
+	if next.IsZero() || !next.After(now) {
+		next = s.rule.Next(now)
+	}
+	if next.IsZero() {
+		return 0
+	}
+	s.planned = next
+	if s.jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(s.jitter))))
+	}
+	return time.Duration(next.UnixNano())
+}
+
+// 周期调度规则
+type Schedule interface {
+	// 返回t之后的下一次触发时间, 零值表示不再触发
+	Next(t time.Time) time.Time
+}
+
+type everySchedule struct {
+	interval time.Duration
+}
+
+// 固定间隔重复
+func Every(interval time.Duration) Schedule {
+	if interval <= 0 {
+		panic(fmt.Errorf("timer every interval error %v", interval))
+	}
+	return &everySchedule{interval: interval}
+}
+
+func (s *everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+type dailySchedule struct {
+	hour, minute int
+	loc          *time.Location
+}
+
+// 每天在指定时区的固定时刻触发, 如每天05:00重置
+// loc为nil时使用本地时区
+func DailyAt(hour, minute int, loc *time.Location) Schedule {
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		panic(fmt.Errorf("timer daily at error %02d:%02d", hour, minute))
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	return &dailySchedule{hour: hour, minute: minute, loc: loc}
+}
+
+func (s *dailySchedule) Next(t time.Time) time.Time {
+	t = t.In(s.loc)
+	next := s.at(t.Year(), t.Month(), t.Day())
+	if !next.After(t) {
+		next = s.at(t.Year(), t.Month(), t.Day()+1)
+	}
+	return next
+}
+
+// 当天的触发时刻 夏令时跳过该时刻时顺延相同时长, 如02:30不存在时为03:30
+func (s *dailySchedule) at(year int, month time.Month, day int) time.Time {
+	t := time.Date(year, month, day, s.hour, s.minute, 0, 0, s.loc)
+	if diff := (s.hour-t.Hour())*60 + s.minute - t.Minute(); diff != 0 {
+		t = t.Add(time.Duration(diff) * time.Minute)
+	}
+	return t
+}
+
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool
+	loc                                   *time.Location
+}
+
+type cronField struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	cronSeconds = cronField{0, 59, nil}
+	cronMinutes = cronField{0, 59, nil}
+	cronHours   = cronField{0, 23, nil}
+	cronDom     = cronField{1, 31, nil}
+	cronMonths  = cronField{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronField{0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// 解析cron表达式
+// 支持5段(分 时 日 月 周)或6段(秒 分 时 日 月 周)
+// 每段支持 * ? a a-b */n a-b/n a/n 及逗号分隔的列表, 月和周支持英文缩写
+// 日和周同时指定时满足其一即触发
+// 夏令时跳过的时刻不触发, 重复的时刻只触发一次
+// loc为nil时使用本地时区
+func Cron(expr string, loc *time.Location) (Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 5 {
+		fields = append([]string{"0"}, fields...)
+	}
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("cron expr %q fields count error", expr)
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	s := &cronSchedule{loc: loc}
+	var err error
+	if s.second, _, err = parseCronField(fields[0], cronSeconds); err != nil {
+		return nil, err
+	}
+	if s.minute, _, err = parseCronField(fields[1], cronMinutes); err != nil {
+		return nil, err
+	}
+	if s.hour, _, err = parseCronField(fields[2], cronHours); err != nil {
+		return nil, err
+	}
+	if s.dom, s.domStar, err = parseCronField(fields[3], cronDom); err != nil {
+		return nil, err
+	}
+	if s.month, _, err = parseCronField(fields[4], cronMonths); err != nil {
+		return nil, err
+	}
+	if s.dow, s.dowStar, err = parseCronField(fields[5], cronDow); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 { // 7和0都表示周日
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// 同Cron, 表达式错误时panic
+func MustCron(expr string, loc *time.Location) Schedule {
+	s, err := Cron(expr, loc)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func parseCronField(field string, f cronField) (bits uint64, star bool, err error) {
+	star = true
+	for _, part := range strings.Split(field, ",") {
+		rangeAndStep := strings.SplitN(part, "/", 2)
+		var lo, hi uint
+		partStar := rangeAndStep[0] == "*" || rangeAndStep[0] == "?"
+		if partStar {
+			lo, hi = f.min, f.max
+		} else {
+			bounds := strings.SplitN(rangeAndStep[0], "-", 2)
+			if lo, err = parseCronValue(bounds[0], f); err != nil {
+				return 0, false, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseCronValue(bounds[1], f); err != nil {
+					return 0, false, err
+				}
+			} else if len(rangeAndStep) == 2 {
+				hi = f.max // a/n 表示从a开始到最大值
+			}
+		}
+		step := uint(1)
+		if len(rangeAndStep) == 2 {
+			n, err := strconv.ParseUint(rangeAndStep[1], 10, 32)
+			if err != nil || n == 0 {
+				return 0, false, fmt.Errorf("cron field %q step error", part)
+			}
+			step = uint(n)
+		}
+		if lo > hi {
+			return 0, false, fmt.Errorf("cron field %q range error", part)
+		}
+		for i := lo; i <= hi; i += step {
+			bits |= 1 << i
+		}
+		star = star && partStar && step == 1
+	}
+	return bits, star, nil
+}
+
+func parseCronValue(s string, f cronField) (uint, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || uint(n) < f.min || uint(n) > f.max {
+		return 0, fmt.Errorf("cron value %q out of range [%d, %d]", s, f.min, f.max)
+	}
+	return uint(n), nil
+}
+
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	from := t.In(s.loc)
+	for t = from; ; {
+		t = s.next(t)
+		// 夏令时结束时重复的时刻 墙上时间不晚于from的跳过
+		if t.IsZero() || wallClock(t).After(wallClock(from)) {
+			return t
+		}
+	}
+}
+
+// 时区内的墙上时间
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	yearLimit := t.Year() + 5
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+	for s.month&(1<<uint(t.Month())) == 0 {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+	for !s.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+		if !next.After(t) {
+			// 下一小时被夏令时跳过
+			next = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+2, 0, 0, 0, s.loc)
+		}
+		t = next
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		t = t.Truncate(time.Minute).Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+	for s.second&(1<<uint(t.Second())) == 0 {
+		t = t.Truncate(time.Second).Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+	return t
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
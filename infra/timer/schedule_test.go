@@ -0,0 +1,187 @@
+package timer
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoc(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestCronNext(t *testing.T) {
+	utc := time.UTC
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04:05", s, utc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	cases := []struct {
+		expr string
+		from string
+		want []string // 依次的触发时间 空串表示不再触发
+	}{
+		{"* * * * *", "2024-01-01 00:00:30", []string{"2024-01-01 00:01:00", "2024-01-01 00:02:00"}},
+		{"*/15 * * * * *", "2024-01-01 00:00:14", []string{"2024-01-01 00:00:15", "2024-01-01 00:00:30", "2024-01-01 00:00:45", "2024-01-01 00:01:00"}},
+		{"0 5 * * *", "2024-01-01 05:00:00", []string{"2024-01-02 05:00:00"}},
+		{"30 4 1 * *", "2024-01-15 00:00:00", []string{"2024-02-01 04:30:00", "2024-03-01 04:30:00"}},
+		{"0 0 29 2 *", "2024-03-01 00:00:00", []string{"2028-02-29 00:00:00"}},
+		{"0 0 31 2 *", "2024-01-01 00:00:00", []string{""}}, // 5年内不会触发
+		{"0 9-17/4 * * *", "2024-01-01 10:00:00", []string{"2024-01-01 13:00:00", "2024-01-01 17:00:00", "2024-01-02 09:00:00"}},
+		{"0 12 * * 1,3,5", "2024-01-01 12:00:00", []string{"2024-01-03 12:00:00", "2024-01-05 12:00:00", "2024-01-08 12:00:00"}},
+		{"0 0 * * sun", "2024-01-01 00:00:00", []string{"2024-01-07 00:00:00"}},
+		{"0 0 * * 7", "2024-01-01 00:00:00", []string{"2024-01-07 00:00:00"}},
+		{"0 0 * * MON-wed", "2024-01-03 12:00:00", []string{"2024-01-08 00:00:00", "2024-01-09 00:00:00"}},
+		{"0 0 1 jan,Jul ?", "2024-02-01 00:00:00", []string{"2024-07-01 00:00:00", "2025-01-01 00:00:00"}},
+		{"0 0 10/10 * *", "2024-01-10 00:00:00", []string{"2024-01-20 00:00:00", "2024-01-30 00:00:00", "2024-02-10 00:00:00"}},
+		// 日和周同时指定时满足其一即可: 每月13号或每周五
+		{"0 0 13 * 5", "2024-09-01 00:00:00", []string{"2024-09-06 00:00:00", "2024-09-13 00:00:00", "2024-09-20 00:00:00"}},
+		// 其一为*时需同时满足
+		{"0 0 * * 5", "2024-09-12 00:00:00", []string{"2024-09-13 00:00:00", "2024-09-20 00:00:00"}},
+		{"0 0 13 * *", "2024-09-12 00:00:00", []string{"2024-09-13 00:00:00", "2024-10-13 00:00:00"}},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			s, err := Cron(c.expr, utc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from := at(c.from)
+			for _, w := range c.want {
+				next := s.Next(from)
+				if w == "" {
+					if !next.IsZero() {
+						t.Fatalf("next after %v = %v, want none", from, next)
+					}
+					return
+				}
+				if want := at(w); !next.Equal(want) {
+					t.Fatalf("next after %v = %v, want %v", from, next, want)
+				}
+				from = next
+			}
+		})
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * foo *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"1,,2 * * * *",
+		"a-b * * * *",
+	} {
+		if _, err := Cron(expr, time.UTC); err == nil {
+			t.Errorf("Cron(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+// 夏令时切换日 America/New_York 2024-03-10 02:00跳到03:00, 2024-11-03 02:00回到01:00
+func TestScheduleDST(t *testing.T) {
+	ny := mustLoc(t, "America/New_York")
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	cases := []struct {
+		name string
+		rule Schedule
+		from time.Time
+		want []time.Time
+	}{
+		{
+			// 不存在的02:30按03:30触发
+			name: "daily spring forward",
+			rule: DailyAt(2, 30, ny),
+			from: at("2024-03-09 03:00"),
+			want: []time.Time{at("2024-03-10 03:30"), at("2024-03-11 02:30")},
+		},
+		{
+			// 重复的01:30只触发一次
+			name: "daily fall back",
+			rule: DailyAt(1, 30, ny),
+			from: at("2024-11-02 12:00"),
+			want: []time.Time{at("2024-11-03 01:30"), at("2024-11-04 01:30")},
+		},
+		{
+			name: "daily keeps wall clock",
+			rule: DailyAt(5, 0, ny),
+			from: at("2024-03-09 06:00"),
+			want: []time.Time{at("2024-03-10 05:00"), at("2024-03-11 05:00")},
+		},
+		{
+			// cron跳过不存在的时刻
+			name: "cron spring forward",
+			rule: MustCron("30 2 * * *", ny),
+			from: at("2024-03-09 03:00"),
+			want: []time.Time{at("2024-03-11 02:30")},
+		},
+		{
+			// 重复的01:30只触发一次
+			name: "cron fall back",
+			rule: MustCron("30 1 * * *", ny),
+			from: at("2024-11-02 12:00"),
+			want: []time.Time{at("2024-11-03 01:30"), at("2024-11-04 01:30")},
+		},
+		{
+			name: "cron hourly across fall back",
+			rule: MustCron("0 * * * *", ny),
+			from: at("2024-11-03 00:30"),
+			want: []time.Time{at("2024-11-03 01:00"), at("2024-11-03 02:00"), at("2024-11-03 03:00")},
+		},
+		{
+			name: "cron hourly across spring forward",
+			rule: MustCron("0 * * * *", ny),
+			from: at("2024-03-10 00:30"),
+			want: []time.Time{at("2024-03-10 01:00"), at("2024-03-10 03:00"), at("2024-03-10 04:00")},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			from := c.from
+			for _, want := range c.want {
+				next := c.rule.Next(from)
+				if !next.Equal(want) {
+					t.Fatalf("next after %v = %v, want %v", from, next, want)
+				}
+				from = next
+			}
+		})
+	}
+}
+
+func TestDailyAtInvalid(t *testing.T) {
+	for _, hm := range [][2]int{{24, 0}, {-1, 0}, {0, 60}, {0, -1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("DailyAt(%d, %d) did not panic", hm[0], hm[1])
+				}
+			}()
+			DailyAt(hm[0], hm[1], time.UTC)
+		}()
+	}
+}
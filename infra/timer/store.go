@@ -0,0 +1,182 @@
+package timer
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/tnnmigga/core/conf"
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/mods/mongo"
+	"github.com/tnnmigga/core/mods/redis"
+	"github.com/tnnmigga/core/msgbus"
+
+	"go.mongodb.org/mongo-driver/bson"
+	driver "go.mongodb.org/mongo-driver/mongo"
+)
+
+// 持久化的定时器数据
+// 定时器属于某个进程的某个模块, 同类型的其他进程不会加载
+type TimerData struct {
+	ID     uint64       `bson:"_id"`
+	Server uint32       `bson:"server"`
+	Owner  idef.ModName `bson:"owner"`
+	Time   int64        `bson:"time"` // 纳秒时间戳
+	Data   []byte       `bson:"data"` // codec.Encode编码后的Ctx
+}
+
+// 定时器持久化后端
+// 所有方法都在模块协程中调用, 实现需通过msgbus异步访问存储模块
+// Ctx需要通过codec注册, 一般已作为消息注册过
+type IStore interface {
+	// 保存定时器
+	Save(caller idef.IModule, t *TimerData)
+	// 删除已触发或已停止的定时器
+	Delete(caller idef.IModule, id uint64)
+	// 加载本进程caller名下所有未完成的定时器, 回调在模块协程执行
+	Load(caller idef.IModule, cb func([]*TimerData, error))
+}
+
+// 通过mongo模块持久化定时器
+type MongoStore struct {
+	CollName string
+}
+
+// 创建mongo存储并在名为mongoMod的mongo模块声明(server, owner, _id)索引
+// 包含_id以支持按_id分页加载
+func NewMongoStore(mongoMod idef.ModName, collName string) *MongoStore {
+	mongo.DeclareIndexes(mongoMod, collName, driver.IndexModel{
+		Keys: bson.D{{Key: "server", Value: 1}, {Key: "owner", Value: 1}, {Key: "_id", Value: 1}},
+	})
+	return &MongoStore{CollName: collName}
+}
+
+func (s *MongoStore) Save(caller idef.IModule, t *TimerData) {
+	b, err := bson.Marshal(t)
+	if err != nil {
+		zlog.Errorf("timer mongo store marshal error %v", err)
+		return
+	}
+	msgbus.Cast(&mongo.MongoSaveSingle{
+		GroupKey: timerGroupKey(t.ID),
+		CollName: s.CollName,
+		Op: &mongo.MongoSaveOp{
+			Filter: bson.M{"_id": t.ID},
+			Value:  b,
+		},
+	})
+}
+
+func (s *MongoStore) Delete(caller idef.IModule, id uint64) {
+	req := &mongo.MongoDeleteOne{
+		GroupKey: timerGroupKey(id),
+		CollName: s.CollName,
+		Filter:   bson.M{"_id": id},
+	}
+	msgbus.RPC(caller, msgbus.Local(), req, func(_ *mongo.MongoResult, err error) {
+		if err != nil {
+			zlog.Errorf("timer mongo store delete %d error %v", id, err)
+		}
+	})
+}
+
+// 每页加载的定时器数量
+const loadPageSize = 1000
+
+// 按_id分页加载 数量不受mongo.max-results限制
+func (s *MongoStore) Load(caller idef.IModule, cb func([]*TimerData, error)) {
+	s.loadPage(caller, "", nil, cb)
+}
+
+func (s *MongoStore) loadPage(caller idef.IModule, token string, ts []*TimerData, cb func([]*TimerData, error)) {
+	req := &mongo.MongoLoadPage{
+		GroupKey: fmt.Sprintf("timer-load-%d-%s", conf.ServerID, caller.Name()),
+		CollName: s.CollName,
+		Filter:   bson.M{"server": conf.ServerID, "owner": caller.Name()},
+		PageSize: loadPageSize,
+		Token:    token,
+	}
+	msgbus.RPC(caller, msgbus.Local(), req, func(page *mongo.MongoPage, err error) {
+		if err != nil {
+			cb(nil, err)
+			return
+		}
+		for _, raw := range page.Docs {
+			t := &TimerData{}
+			if err := bson.Unmarshal(raw, t); err != nil {
+				zlog.Errorf("timer mongo store unmarshal error %v", err)
+				continue
+			}
+			ts = append(ts, t)
+		}
+		if page.Next == "" {
+			cb(ts, nil)
+			return
+		}
+		s.loadPage(caller, page.Next, ts, cb)
+	})
+}
+
+// 通过redis模块持久化定时器
+// 每个进程每个模块的定时器保存在一个hash中, key为 Prefix:serverID:模块名
+type RedisStore struct {
+	Prefix string
+}
+
+func (s *RedisStore) key(owner idef.ModName) string {
+	return fmt.Sprintf("%s:%d:%s", s.Prefix, conf.ServerID, owner)
+}
+
+func (s *RedisStore) Save(caller idef.IModule, t *TimerData) {
+	b, err := bson.Marshal(t)
+	if err != nil {
+		zlog.Errorf("timer redis store marshal error %v", err)
+		return
+	}
+	s.exec(caller, t.ID, "HSET", s.key(caller.Name()), strconv.FormatUint(t.ID, 10), b)
+}
+
+func (s *RedisStore) Delete(caller idef.IModule, id uint64) {
+	s.exec(caller, id, "HDEL", s.key(caller.Name()), strconv.FormatUint(id, 10))
+}
+
+func (s *RedisStore) exec(caller idef.IModule, id uint64, cmd ...any) {
+	req := &redis.Exec{
+		Cmd: cmd,
+		Key: timerGroupKey(id),
+	}
+	msgbus.RPC(caller, msgbus.Local(), req, func(_ any, err error) {
+		if err != nil {
+			zlog.Errorf("timer redis store %v error %v", cmd[0], err)
+		}
+	})
+}
+
+func (s *RedisStore) Load(caller idef.IModule, cb func([]*TimerData, error)) {
+	req := &redis.Exec{
+		Cmd: []any{"HGETALL", s.key(caller.Name())},
+	}
+	msgbus.RPC(caller, msgbus.Local(), req, func(res any, err error) {
+		if err != nil {
+			cb(nil, err)
+			return
+		}
+		items, _ := res.([]any)
+		ts := make([]*TimerData, 0, len(items)/2)
+		for i := 1; i < len(items); i += 2 {
+			value, _ := items[i].(string)
+			t := &TimerData{}
+			if err := bson.Unmarshal([]byte(value), t); err != nil {
+				zlog.Errorf("timer redis store unmarshal error %v", err)
+				continue
+			}
+			ts = append(ts, t)
+		}
+		cb(ts, nil)
+	})
+}
+
+// 同一个定时器的写操作保证时序
+func timerGroupKey(id uint64) string {
+	return fmt.Sprintf("timer-%d", id)
+}
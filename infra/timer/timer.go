@@ -3,24 +3,48 @@ package timer
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/tnnmigga/core/algorithm"
+	"github.com/tnnmigga/core/codec"
 	"github.com/tnnmigga/core/conc"
+	"github.com/tnnmigga/core/conf"
 	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/zlog"
 	"github.com/tnnmigga/core/msgbus"
 	"github.com/tnnmigga/core/utils"
+	"github.com/tnnmigga/core/utils/clock"
 	"github.com/tnnmigga/core/utils/idgen"
 
 	"time"
 )
 
+var (
+	heaps   = map[idef.ModName]*TimerHeap{}
+	heapsMu sync.Mutex
+)
+
 type timerTrigger struct {
 }
 
-type timerCtx struct {
+// 读取定时器快照
+// 由模块协程处理后通过resp返回
+type timerSnapshot struct {
+	resp chan []*TimerInfo
+}
+
+// 定时器信息 用于展示
+type TimerInfo struct {
 	ID   uint64
-	Time time.Duration
-	Ctx  any
+	Time time.Time
+	Ctx  string
+}
+
+type timerCtx struct {
+	ID    uint64
+	Time  time.Duration
+	Ctx   any
+	sched *schedule // 周期定时器
 }
 
 func (t *timerCtx) String() string {
@@ -35,36 +59,152 @@ func (t *timerCtx) Value() time.Duration {
 	return t.Time
 }
 
+// 进程重启后对已过期定时器的处理方式
+type CatchUpPolicy int
+
+const (
+	CatchUpFire    CatchUpPolicy = iota // 启动后立即触发
+	CatchUpDiscard                      // 直接丢弃
+)
+
 type TimerHeap struct {
 	algorithm.Heap[uint64, time.Duration, *timerCtx]
-	module idef.IModule
-	timer  *time.Timer
+	module  idef.IModule
+	armed   time.Duration      // 当前已设置的触发时间 0表示未设置
+	rearm   chan time.Duration // 向tick协程提交新的触发时间
+	closed  chan struct{}      // 模块停止后关闭 结束tick协程
+	store   IStore
+	catchUp CatchUpPolicy
+	maxLate time.Duration // CatchUpFire时超过此时长的过期定时器仍丢弃 0表示不限制
+}
+
+type Option func(*TimerHeap)
+
+// 持久化定时器 进程重启后在运行阶段恢复
+func WithStore(store IStore) Option {
+	return func(h *TimerHeap) {
+		h.store = store
+	}
+}
+
+// 设置重启后过期定时器的处理方式
+// maxLate仅对CatchUpFire生效, 过期超过maxLate的定时器会被丢弃, 0表示不限制
+func WithCatchUp(policy CatchUpPolicy, maxLate time.Duration) Option {
+	return func(h *TimerHeap) {
+		h.catchUp = policy
+		h.maxLate = maxLate
+	}
 }
 
-func NewTimerHeap(m idef.IModule) *TimerHeap {
+func NewTimerHeap(m idef.IModule, opts ...Option) *TimerHeap {
 	h := &TimerHeap{
 		module: m,
+		rearm:  make(chan time.Duration, 1),
+		closed: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.store != nil {
+		m.After(idef.ServerStateRun, h.restore)
 	}
 	msgbus.RegisterHandler(m, h.onTimerTrigger)
+	msgbus.RegisterHandler(m, h.onTimerSnapshot)
+	m.After(idef.ServerStateStop, h.afterStop)
+	conc.Go(h.tick)
+	heapsMu.Lock()
+	heaps[m.Name()] = h
+	heapsMu.Unlock()
 	return h
 }
 
+// 模块停止(包括运行时卸载)后结束tick协程并移除注册
+// 同名模块重新添加时会创建新的定时器
+func (h *TimerHeap) afterStop() error {
+	heapsMu.Lock()
+	if heaps[h.module.Name()] == h {
+		delete(heaps, h.module.Name())
+	}
+	heapsMu.Unlock()
+	close(h.closed)
+	return nil
+}
+
+// 查找模块的定时器
+func FindTimerHeap(name idef.ModName) (*TimerHeap, bool) {
+	heapsMu.Lock()
+	defer heapsMu.Unlock()
+	h, ok := heaps[name]
+	return h, ok
+}
+
+// 获取当前所有定时器的快照
+// 可在模块协程外调用, 模块繁忙时超时返回错误
+func (h *TimerHeap) Snapshot(timeout time.Duration) ([]*TimerInfo, error) {
+	req := &timerSnapshot{
+		resp: make(chan []*TimerInfo, 1),
+	}
+	h.module.Assign(req)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case infos := <-req.resp:
+		return infos, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("timer snapshot timeout %s", h.module.Name())
+	}
+}
+
 func (h *TimerHeap) New(delay time.Duration, ctx any) uint64 {
 	t := &timerCtx{
 		ID:   idgen.NewUUID(),
 		Time: utils.NowNs() + delay,
 		Ctx:  ctx,
 	}
+	if h.store != nil {
+		h.store.Save(h.module, &TimerData{
+			ID:     t.ID,
+			Server: conf.ServerID,
+			Owner:  h.module.Name(),
+			Time:   int64(t.Time),
+			Data:   codec.Encode(ctx),
+		})
+	}
+	h.push(t)
+	return t.ID
+}
+
+func (h *TimerHeap) push(t *timerCtx) {
 	top := h.Top()
 	h.Push(t)
 	if top != nil && top.Time <= t.Time {
-		return t.ID
-	}
-	if h.timer != nil {
-		h.timer.Stop()
-		h.timer = nil
+		return
 	}
 	h.tryNextTrigger()
+}
+
+// 创建周期定时器
+// 每次触发时将ctx投递给所属模块, 返回的id可用于Stop停止整个周期
+// 周期定时器不会持久化, 需要在模块初始化时重新创建
+func (h *TimerHeap) NewSchedule(rule Schedule, ctx any, opts ...ScheduleOption) uint64 {
+	s := &schedule{
+		rule:      rule,
+		threshold: time.Second,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	next := s.next(time.Unix(0, int64(utils.NowNs())))
+	if next == 0 {
+		return 0
+	}
+	t := &timerCtx{
+		ID:    idgen.NewUUID(),
+		Time:  next,
+		Ctx:   ctx,
+		sched: s,
+	}
+	h.push(t)
 	return t.ID
 }
 
@@ -73,33 +213,90 @@ func (h *TimerHeap) Stop(id uint64) bool {
 	if index == -1 {
 		return false
 	}
-	h.RemoveByIndex(index)
+	t := h.RemoveByIndex(index)
+	if h.store != nil && t.sched == nil {
+		h.store.Delete(h.module, id)
+	}
 	return true
 }
 
+// 从持久化后端恢复定时器
+func (h *TimerHeap) restore() error {
+	h.store.Load(h.module, func(ts []*TimerData, err error) {
+		if err != nil {
+			zlog.Errorf("timer restore %s error %v", h.module.Name(), err)
+			return
+		}
+		nowNs := utils.NowNs()
+		for _, data := range ts {
+			if h.Find(data.ID) != -1 {
+				continue
+			}
+			late := nowNs - time.Duration(data.Time)
+			if late > 0 && (h.catchUp == CatchUpDiscard || (h.maxLate > 0 && late > h.maxLate)) {
+				zlog.Warnf("timer restore discard %d, late %v", data.ID, late)
+				h.store.Delete(h.module, data.ID)
+				continue
+			}
+			ctx, err := codec.Decode(data.Data)
+			if err != nil {
+				zlog.Errorf("timer restore decode %d error %v", data.ID, err)
+				h.store.Delete(h.module, data.ID)
+				continue
+			}
+			h.push(&timerCtx{
+				ID:   data.ID,
+				Time: time.Duration(data.Time),
+				Ctx:  ctx,
+			})
+		}
+		zlog.Infof("timer restore %s %d timers", h.module.Name(), len(ts))
+	})
+	return nil
+}
+
 func (h *TimerHeap) tryNextTrigger() {
 	top := h.Top()
-	if top == nil {
+	if top == nil || top.Time == h.armed {
 		return
 	}
-	nowNs := utils.NowNs()
-	if top.Time <= nowNs {
-		h.trigger()
-		return
+	h.armed = top.Time
+	// 仅模块协程写入, 丢弃未被取走的旧时间后必然可以写入
+	select {
+	case <-h.rearm:
+	default:
 	}
-	h.timer = time.NewTimer(time.Duration(top.Time - utils.NowNs()))
-	conc.Go(func(ctx context.Context) {
+	h.rearm <- top.Time
+}
+
+// 每个TimerHeap共用一个tick协程
+// 只等待堆顶的触发时间, 堆顶变化时由模块协程通过rearm重置
+func (h *TimerHeap) tick(ctx context.Context) {
+	timer := clock.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C()
+	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-h.timer.C:
+		case <-h.closed:
+			return
+		case at := <-h.rearm:
+			if !timer.Stop() {
+				select {
+				case <-timer.C():
+				default:
+				}
+			}
+			timer.Reset(at - utils.NowNs())
+		case <-timer.C():
 			h.trigger()
 		}
-	})
+	}
 }
 
 func (h *TimerHeap) trigger() {
-	// 此函数的执行协程为模块协程外的临时开辟的协程
+	// 此函数的执行协程为模块协程外的tick协程
 	// 若直接操作数据会存在并发问题
 	// 因此是投递消息给模块由模块协程来操作定时器数据
 	h.module.Assign(&timerTrigger{})
@@ -0,0 +1,119 @@
+package timer
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/mods/basic"
+	"github.com/tnnmigga/core/msgbus"
+)
+
+// 定时器触发时投递的消息
+type benchFired struct{}
+
+var (
+	benchRecver     *basic.Module
+	benchRecverOnce sync.Once
+	benchModSeq     atomic.Int32 // 同一benchmark会以不同b.N多次执行, 模块名需唯一
+)
+
+// 接收触发消息的模块 避免Cast找不到接收者
+func startBenchRecver() {
+	benchRecverOnce.Do(func() {
+		benchRecver = basic.New("bench-recver", basic.DefaultMQLen)
+		msgbus.RegisterHandler(benchRecver, func(*benchFired) {})
+		go benchRecver.Run()
+	})
+}
+
+func newBenchModule(tb testing.TB) *basic.Module {
+	startBenchRecver()
+	return basic.New(idef.ModName(fmt.Sprintf("%s-%d", tb.Name(), benchModSeq.Add(1))), basic.DefaultMQLen)
+}
+
+func BenchmarkTimerHeapNew(b *testing.B) {
+	h := NewTimerHeap(newBenchModule(b))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.New(time.Duration(rand.Intn(3600))*time.Second, &benchFired{})
+	}
+}
+
+// 1e5个定时器时取消一个再新建一个
+func BenchmarkTimerHeapCancel1e5(b *testing.B) {
+	const n = 100000
+	h := NewTimerHeap(newBenchModule(b))
+	ids := make([]uint64, n)
+	for i := range ids {
+		ids[i] = h.New(time.Duration(rand.Intn(3600))*time.Second, &benchFired{})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		j := rand.Intn(n)
+		h.Stop(ids[j])
+		ids[j] = h.New(time.Duration(rand.Intn(3600))*time.Second, &benchFired{})
+	}
+}
+
+// 1秒内分布的定时器全部到期后触发
+func BenchmarkTimerHeapFire(b *testing.B) {
+	h := NewTimerHeap(newBenchModule(b))
+	for i := 0; i < b.N; i++ {
+		h.New(time.Duration(rand.Intn(1000))*time.Millisecond, &benchFired{})
+	}
+	// 整体提前1秒 不改变堆序
+	for _, t := range h.Items {
+		t.Time -= time.Second
+	}
+	b.ResetTimer()
+	h.onTimerTrigger(&timerTrigger{})
+	b.StopTimer()
+	if h.Len() != 0 {
+		b.Fatalf("timer heap remain %d", h.Len())
+	}
+}
+
+func BenchmarkTimerWheelNew(b *testing.B) {
+	w := NewTimerWheel(newBenchModule(b), 0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.New(time.Duration(rand.Intn(3600))*time.Second, &benchFired{})
+	}
+}
+
+// 1e5个定时器时取消一个再新建一个
+func BenchmarkTimerWheelCancel1e5(b *testing.B) {
+	const n = 100000
+	w := NewTimerWheel(newBenchModule(b), 0)
+	ids := make([]uint64, n)
+	for i := range ids {
+		ids[i] = w.New(time.Duration(rand.Intn(3600))*time.Second, &benchFired{})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		j := rand.Intn(n)
+		w.Stop(ids[j])
+		ids[j] = w.New(time.Duration(rand.Intn(3600))*time.Second, &benchFired{})
+	}
+}
+
+// 1秒内分布的定时器全部到期后触发
+func BenchmarkTimerWheelFire(b *testing.B) {
+	w := NewTimerWheel(newBenchModule(b), 0)
+	for i := 0; i < b.N; i++ {
+		w.New(time.Duration(rand.Intn(1000))*time.Millisecond, &benchFired{})
+	}
+	// 起点提前2秒 使所有定时器到期
+	w.start -= 2 * time.Second
+	b.ResetTimer()
+	w.onWheelTick(&wheelTick{})
+	b.StopTimer()
+	if w.Len() != 0 {
+		b.Fatalf("timer wheel remain %d", w.Len())
+	}
+}
@@ -0,0 +1,260 @@
+package timer
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/tnnmigga/core/conc"
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/msgbus"
+	"github.com/tnnmigga/core/utils"
+	"github.com/tnnmigga/core/utils/clock"
+	"github.com/tnnmigga/core/utils/idgen"
+)
+
+// 定时器通用接口 TimerHeap和TimerWheel均实现
+type ITimer interface {
+	New(delay time.Duration, ctx any) uint64
+	Stop(id uint64) bool
+}
+
+const (
+	wheelBits0  = 8 // 第一层256个槽
+	wheelBitsN  = 6 // 其余每层64个槽
+	wheelLevels = 5
+	wheelMask0  = 1<<wheelBits0 - 1
+	wheelMaskN  = 1<<wheelBitsN - 1
+	wheelMax    = 1<<(wheelBits0+wheelBitsN*(wheelLevels-1)) - 1 // 可表示的最大tick数
+	wheelWaits  = 100                                            // tick消息未被处理时最多等待的tick数
+)
+
+type wheelTick struct {
+}
+
+type wheelTimer struct {
+	id     uint64
+	expire uint64 // 到期的tick
+	ctx    any
+	prev   *wheelTimer
+	next   *wheelTimer
+}
+
+// 侵入式双向链表 root为哨兵
+type wheelList struct {
+	root wheelTimer
+}
+
+func (l *wheelList) init() {
+	l.root.prev = &l.root
+	l.root.next = &l.root
+}
+
+func (l *wheelList) empty() bool {
+	return l.root.next == &l.root
+}
+
+func (l *wheelList) push(t *wheelTimer) {
+	t.prev = l.root.prev
+	t.next = &l.root
+	l.root.prev.next = t
+	l.root.prev = t
+}
+
+func (l *wheelList) popFront() *wheelTimer {
+	if l.empty() {
+		return nil
+	}
+	t := l.root.next
+	t.unlink()
+	return t
+}
+
+func (t *wheelTimer) unlink() {
+	t.prev.next = t.next
+	t.next.prev = t.prev
+	t.prev, t.next = nil, nil
+}
+
+// 分层时间轮
+// 适合大量短周期定时器, New和Stop均为O(1), 精度为tick
+// 每个时间轮一个tick协程, 有定时器时按tick投递消息给模块协程推进
+// 同一模块只能创建一个TimerWheel, 不支持持久化
+type TimerWheel struct {
+	module  idef.IModule
+	tick    time.Duration
+	start   time.Duration // 创建时间 tick以此为起点
+	current uint64        // 下一个待处理的tick
+	levels  [wheelLevels][]wheelList
+	timers  map[uint64]*wheelTimer
+	size    atomic.Int64 // 定时器数量 供tick协程判断是否需要推进
+	pending atomic.Bool  // 是否有未处理的tick消息, 模块繁忙时合并
+	ctx     context.Context
+	cancel  context.CancelFunc // 模块停止后取消 结束tick协程
+}
+
+// 创建时间轮 tick为精度, 默认10ms
+func NewTimerWheel(m idef.IModule, tick time.Duration) *TimerWheel {
+	if tick <= 0 {
+		tick = 10 * time.Millisecond
+	}
+	w := &TimerWheel{
+		module: m,
+		tick:   tick,
+		start:  utils.NowNs(),
+		timers: map[uint64]*wheelTimer{},
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	for i := range w.levels {
+		n := utils.IfElse(i == 0, 1<<wheelBits0, 1<<wheelBitsN)
+		w.levels[i] = make([]wheelList, n)
+		for j := range w.levels[i] {
+			w.levels[i][j].init()
+		}
+	}
+	msgbus.RegisterHandler(m, w.onWheelTick)
+	m.After(idef.ServerStateStop, w.afterStop)
+	conc.Go(w.run)
+	return w
+}
+
+func (w *TimerWheel) afterStop() error {
+	w.cancel()
+	return nil
+}
+
+func (w *TimerWheel) New(delay time.Duration, ctx any) uint64 {
+	now := w.nowTick()
+	if len(w.timers) == 0 && w.current < now {
+		// 空闲期间没有推进, 直接对齐到当前时间
+		w.current = now
+	}
+	ticks := uint64(0)
+	if delay > 0 {
+		ticks = uint64((delay + w.tick - 1) / w.tick)
+	}
+	t := &wheelTimer{
+		id:     idgen.NewUUID(),
+		expire: utils.Max(w.current, now) + ticks,
+		ctx:    ctx,
+	}
+	w.timers[t.id] = t
+	w.size.Add(1)
+	w.add(t)
+	return t.id
+}
+
+func (w *TimerWheel) Stop(id uint64) bool {
+	t, ok := w.timers[id]
+	if !ok {
+		return false
+	}
+	delete(w.timers, id)
+	w.size.Add(-1)
+	t.unlink()
+	return true
+}
+
+// 定时器数量
+func (w *TimerWheel) Len() int {
+	return len(w.timers)
+}
+
+func (w *TimerWheel) add(t *wheelTimer) {
+	delta := t.expire - w.current
+	if t.expire < w.current {
+		delta = 0
+		t.expire = w.current
+	}
+	if delta > wheelMax {
+		// 超出范围的先放在最高层, 级联时重新计算
+		delta = wheelMax
+	}
+	if delta <= wheelMask0 {
+		w.levels[0][t.expire&wheelMask0].push(t)
+		return
+	}
+	for lv := 1; lv < wheelLevels; lv++ {
+		shift := wheelBits0 + wheelBitsN*lv
+		if lv == wheelLevels-1 || delta < 1<<shift {
+			expire := utils.Min(t.expire, w.current+wheelMax)
+			index := (expire >> (shift - wheelBitsN)) & wheelMaskN
+			w.levels[lv][index].push(t)
+			return
+		}
+	}
+}
+
+// 将高层槽内的定时器重新分配到低层
+func (w *TimerWheel) cascade(lv int, index uint64) {
+	l := &w.levels[lv][index]
+	var ts []*wheelTimer
+	for t := l.popFront(); t != nil; t = l.popFront() {
+		ts = append(ts, t)
+	}
+	for _, t := range ts {
+		w.add(t)
+	}
+}
+
+// 处理一个tick
+func (w *TimerWheel) step() {
+	index := w.current & wheelMask0
+	if index == 0 {
+		for lv := 1; lv < wheelLevels; lv++ {
+			i := (w.current >> (wheelBits0 + wheelBitsN*(lv-1))) & wheelMaskN
+			w.cascade(lv, i)
+			if i != 0 {
+				break
+			}
+		}
+	}
+	l := &w.levels[0][index]
+	for t := l.popFront(); t != nil; t = l.popFront() {
+		delete(w.timers, t.id)
+		w.size.Add(-1)
+		msgbus.Cast(t.ctx)
+	}
+	w.current++
+}
+
+func (w *TimerWheel) nowTick() uint64 {
+	return uint64((utils.NowNs() - w.start) / w.tick)
+}
+
+func (w *TimerWheel) onWheelTick(msg *wheelTick) {
+	w.pending.Store(false)
+	now := w.nowTick()
+	for w.current <= now && len(w.timers) > 0 {
+		w.step()
+	}
+	if len(w.timers) == 0 && w.current <= now {
+		w.current = now + 1
+	}
+}
+
+// tick协程 仅在有定时器时投递推进消息
+func (w *TimerWheel) run(ctx context.Context) {
+	ticker := clock.NewTicker(w.tick)
+	defer ticker.Stop()
+	waits := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C():
+			if w.size.Load() == 0 {
+				continue
+			}
+			// 消息可能因邮箱已满被丢弃, 长时间未处理时重新投递
+			if w.pending.Swap(true) && waits < wheelWaits {
+				waits++
+				continue
+			}
+			waits = 0
+			w.module.Assign(&wheelTick{})
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package timer
+
+import (
+	"testing"
+	"time"
+)
+
+// 精度为1小时的时间轮 测试期间的真实时间流逝不会推进tick
+func newTestWheel(t *testing.T) *TimerWheel {
+	w := NewTimerWheel(newBenchModule(t), time.Hour)
+	t.Cleanup(func() { w.afterStop() })
+	return w
+}
+
+// 推进n个tick但不处理
+func (w *TimerWheel) lag(n uint64) {
+	w.start -= time.Duration(n) * w.tick
+}
+
+// 推进n个tick并处理到期的定时器
+func (w *TimerWheel) advance(n uint64) {
+	w.lag(n)
+	w.onWheelTick(&wheelTick{})
+}
+
+func (w *TimerWheel) pendingTimer(id uint64) bool {
+	_, ok := w.timers[id]
+	return ok
+}
+
+func TestTimerWheelFire(t *testing.T) {
+	w := newTestWheel(t)
+	id := w.New(3*time.Hour, &benchFired{})
+	w.advance(2)
+	if !w.pendingTimer(id) {
+		t.Fatal("timer fired early")
+	}
+	w.advance(1)
+	if w.pendingTimer(id) || w.Len() != 0 {
+		t.Fatal("timer not fired")
+	}
+}
+
+// 跨层级的定时器在级联后按时触发
+// 当前tick已处理时从下一个tick开始计算, 不会早于delay触发, 最多晚一个tick
+func TestTimerWheelCascade(t *testing.T) {
+	cases := []struct {
+		name  string
+		ticks uint64
+	}{
+		{"level0 last slot", 1<<wheelBits0 - 1},
+		{"level1", 300},
+		{"level1 boundary", 1 << wheelBits0},
+		{"level2", 1<<(wheelBits0+wheelBitsN) + 5},
+		{"level3", 1<<(wheelBits0+2*wheelBitsN) + 7},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := newTestWheel(t)
+			w.advance(17) // 起点不与槽位对齐
+			id := w.New(time.Duration(c.ticks)*time.Hour, &benchFired{})
+			w.advance(c.ticks)
+			if !w.pendingTimer(id) {
+				t.Fatalf("timer fired before tick %d", c.ticks)
+			}
+			w.advance(1)
+			if w.pendingTimer(id) {
+				t.Fatalf("timer not fired at tick %d", c.ticks+1)
+			}
+		})
+	}
+}
+
+func TestTimerWheelStop(t *testing.T) {
+	w := newTestWheel(t)
+	a := w.New(2*time.Hour, &benchFired{})
+	b := w.New(2*time.Hour, &benchFired{}) // 同一槽位
+	c := w.New(500*time.Hour, &benchFired{})
+	if !w.Stop(a) || !w.Stop(c) {
+		t.Fatal("stop pending timer failed")
+	}
+	if w.Stop(a) {
+		t.Fatal("stop twice succeeded")
+	}
+	if w.Len() != 1 || w.size.Load() != 1 {
+		t.Fatalf("len %d size %d after stop", w.Len(), w.size.Load())
+	}
+	w.advance(2)
+	if w.pendingTimer(b) || w.Len() != 0 {
+		t.Fatal("timer in the same slot not fired")
+	}
+	if w.Stop(b) {
+		t.Fatal("stop fired timer succeeded")
+	}
+}
+
+// 模块繁忙未及时处理tick时 新建的定时器从当前时间开始计算
+func TestTimerWheelNewWhileLagging(t *testing.T) {
+	w := newTestWheel(t)
+	a := w.New(10*time.Hour, &benchFired{})
+	w.lag(50)
+	b := w.New(5*time.Hour, &benchFired{})
+	w.advance(0)
+	if w.pendingTimer(a) {
+		t.Fatal("lagging timer not fired")
+	}
+	if !w.pendingTimer(b) {
+		t.Fatal("timer created while lagging fired early")
+	}
+	w.advance(4)
+	if !w.pendingTimer(b) {
+		t.Fatal("timer created while lagging fired early")
+	}
+	w.advance(1)
+	if w.pendingTimer(b) {
+		t.Fatal("timer created while lagging not fired")
+	}
+}
+
+// 空闲期间不推进 新建时对齐到当前时间
+func TestTimerWheelNewAfterIdle(t *testing.T) {
+	w := newTestWheel(t)
+	w.lag(1000)
+	id := w.New(time.Hour, &benchFired{})
+	w.advance(0)
+	if !w.pendingTimer(id) {
+		t.Fatal("timer fired early after idle")
+	}
+	w.advance(1)
+	if w.pendingTimer(id) {
+		t.Fatal("timer not fired after idle")
+	}
+}
@@ -0,0 +1,222 @@
+package zlog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tnnmigga/core/conf"
+)
+
+// 审计日志
+// 与普通日志分开存储, 仅追加写入, 每行一条JSON记录
+// 每条记录包含上一条记录的哈希, 任意记录被修改或删除都会导致校验失败
+// 行格式: {"hash": sha256(prev + record), "record": {...}}
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	seq  uint64
+	prev string
+}
+
+type auditRecord struct {
+	Seq      uint64         `json:"seq"`
+	Time     string         `json:"time"`
+	ServerID uint32         `json:"server_id"`
+	Action   string         `json:"action"`
+	Fields   map[string]any `json:"fields,omitempty"`
+	Prev     string         `json:"prev"`
+}
+
+type auditLine struct {
+	Hash   string          `json:"hash"`
+	Record json.RawMessage `json:"record"`
+}
+
+var audit = &auditLog{}
+
+func init() {
+	conf.OnLoad(func(b []byte) {
+		sum := sha256.Sum256(b)
+		Audit("config-load", "sha256", hex.EncodeToString(sum[:]))
+	})
+}
+
+// 写入一条审计日志
+// keysAndValues为交替的key和value
+// 写入失败时输出到普通错误日志
+func Audit(action string, keysAndValues ...any) {
+	if err := audit.write(action, keysAndValues); err != nil {
+		Errorf("zlog audit %s error %v", action, err)
+	}
+}
+
+func (a *auditLog) write(action string, keysAndValues []any) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		if err := a.open(conf.String("zlog.audit.path", "logs/audit.log")); err != nil {
+			return err
+		}
+	}
+	record := &auditRecord{
+		Seq:      a.seq + 1,
+		Time:     time.Now().Format(time.RFC3339Nano),
+		ServerID: conf.ServerID,
+		Action:   action,
+		Fields:   fieldsMap(keysAndValues),
+		Prev:     a.prev,
+	}
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	hash := auditHash(a.prev, body)
+	line, err := json.Marshal(&auditLine{
+		Hash:   hash,
+		Record: body,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := a.file.Sync(); err != nil {
+		return err
+	}
+	a.seq = record.Seq
+	a.prev = hash
+	return nil
+}
+
+// 打开审计日志并从最后一条记录恢复序号和哈希
+func (a *auditLog) open(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	last, err := lastLine(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if len(last) > 0 {
+		line := &auditLine{}
+		record := &auditRecord{}
+		if err := json.Unmarshal(last, line); err != nil {
+			file.Close()
+			return fmt.Errorf("audit log last line broken: %v", err)
+		}
+		if err := json.Unmarshal(line.Record, record); err != nil {
+			file.Close()
+			return fmt.Errorf("audit log last record broken: %v", err)
+		}
+		a.seq = record.Seq
+		a.prev = line.Hash
+	}
+	a.path = path
+	a.file = file
+	return nil
+}
+
+// 校验审计日志的哈希链, 返回第一个不一致的位置
+func VerifyAudit(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var prev string
+	var seq uint64
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		line := &auditLine{}
+		record := &auditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), line); err != nil {
+			return fmt.Errorf("audit line after seq %d broken: %v", seq, err)
+		}
+		if err := json.Unmarshal(line.Record, record); err != nil {
+			return fmt.Errorf("audit record after seq %d broken: %v", seq, err)
+		}
+		if record.Seq != seq+1 {
+			return fmt.Errorf("audit seq %d discontinuous, expect %d", record.Seq, seq+1)
+		}
+		if record.Prev != prev {
+			return fmt.Errorf("audit seq %d prev hash mismatch", record.Seq)
+		}
+		if auditHash(prev, line.Record) != line.Hash {
+			return fmt.Errorf("audit seq %d hash mismatch", record.Seq)
+		}
+		prev = line.Hash
+		seq = record.Seq
+	}
+	return scanner.Err()
+}
+
+func auditHash(prev string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(prev))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func fieldsMap(keysAndValues []any) map[string]any {
+	if len(keysAndValues) == 0 {
+		return nil
+	}
+	fields := make(map[string]any, len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		if i+1 >= len(keysAndValues) {
+			fields[key] = nil
+			break
+		}
+		fields[key] = keysAndValues[i+1]
+	}
+	return fields
+}
+
+// 读取文件最后一个非空行
+func lastLine(file *os.File) ([]byte, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	const chunk = 64 * 1024
+	var buf []byte
+	for offset := size; offset > 0; {
+		n := int64(chunk)
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		part := make([]byte, n)
+		if _, err := file.ReadAt(part, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		buf = append(part, buf...)
+		trimmed := bytes.TrimRight(buf, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+	}
+	return bytes.TrimRight(buf, "\n"), nil
+}
@@ -0,0 +1,127 @@
+package zlog
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/tnnmigga/core/conf"
+)
+
+// 按调用点限流
+// 每个调用点一个令牌桶, 令牌耗尽后的日志被丢弃
+// sample大于0时被丢弃的日志每sample条仍输出一条
+// 周期性输出每个调用点被丢弃的数量
+type limiter struct {
+	mu       sync.Mutex
+	sites    map[uintptr]*bucket
+	rate     float64 // 每秒补充的令牌数
+	burst    float64 // 令牌桶容量
+	sample   int64
+	interval time.Duration
+	once     sync.Once
+}
+
+type bucket struct {
+	tokens     float64
+	last       time.Time
+	suppressed int64 // 上次汇总后丢弃的数量
+	site       string
+}
+
+var limit = &limiter{
+	sites:    map[uintptr]*bucket{},
+	rate:     conf.Float64("zlog.limit.rate", 10),
+	burst:    conf.Float64("zlog.limit.burst", 20),
+	sample:   conf.Int64("zlog.limit.sample", 0),
+	interval: time.Duration(conf.Int("zlog.limit.interval", 10)) * time.Second,
+}
+
+func (l *limiter) allow(pc uintptr) bool {
+	l.once.Do(func() {
+		go l.report()
+	})
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.sites[pc]
+	if !ok {
+		b = &bucket{
+			tokens: l.burst,
+			last:   now,
+			site:   siteName(pc),
+		}
+		l.sites[pc] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true
+	}
+	b.suppressed++
+	return l.sample > 0 && b.suppressed%l.sample == 0
+}
+
+// 周期性输出被丢弃的日志数量
+func (l *limiter) report() {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		l.mu.Lock()
+		var lines []string
+		for pc, b := range l.sites {
+			if b.suppressed > 0 {
+				lines = append(lines, fmt.Sprintf("suppressed %d similar messages at %s", b.suppressed, b.site))
+				b.suppressed = 0
+			} else if b.tokens >= l.burst {
+				delete(l.sites, pc) // 长时间未触发的调用点
+			}
+		}
+		l.mu.Unlock()
+		for _, line := range lines {
+			base.Warn(line)
+		}
+	}
+}
+
+func siteName(pc uintptr) string {
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return "unknown"
+	}
+	file, line := fn.FileLine(pc)
+	return fmt.Sprintf("%s %s:%d", fn.Name(), file, line)
+}
+
+func callerPC() uintptr {
+	pc, _, _, ok := runtime.Caller(2)
+	if !ok {
+		return 0
+	}
+	return pc
+}
+
+// 按调用点限流的日志
+// 用于高频出错的路径, 参数较大时配合utils.Lazy延迟格式化
+func LimitErrorf(format string, args ...any) {
+	if limit.allow(callerPC()) {
+		logger.Errorf(format, args...)
+	}
+}
+
+func LimitWarnf(format string, args ...any) {
+	if limit.allow(callerPC()) {
+		logger.Warnf(format, args...)
+	}
+}
+
+func LimitInfof(format string, args ...any) {
+	if limit.allow(callerPC()) {
+		logger.Infof(format, args...)
+	}
+}
@@ -0,0 +1,269 @@
+package zlog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	backupTimeFormat = "20060102T150405.000"
+	compressSuffix   = ".gz"
+)
+
+// 日志文件滚动配置
+type rotateConfig struct {
+	MaxSize    int64         // 单个文件最大字节数 0表示不按大小滚动
+	Interval   string        // 按时间滚动 hourly/daily 空表示不按时间滚动
+	MaxBackups int           // 最多保留的历史文件数 0表示不限制
+	MaxAge     time.Duration // 历史文件最长保留时间 0表示不限制
+	Compress   bool          // 历史文件是否gzip压缩
+}
+
+// 支持按大小和时间滚动的日志文件
+// 滚动后的历史文件命名为 name-20060102T150405.000.ext[.gz]
+type rotateWriter struct {
+	mu         sync.Mutex
+	path       string
+	cfg        rotateConfig
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+	millCh     chan struct{}
+}
+
+func newRotateWriter(path string, cfg rotateConfig) (*rotateWriter, error) {
+	w := &rotateWriter{
+		path:   path,
+		cfg:    cfg,
+		millCh: make(chan struct{}, 1),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	go w.millRun()
+	w.mill()
+	return w, nil
+}
+
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotateWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+func (w *rotateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *rotateWriter) shouldRotate(n int64) bool {
+	if w.cfg.MaxSize > 0 && w.size > 0 && w.size+n > w.cfg.MaxSize {
+		return true
+	}
+	return !w.nextRotate.IsZero() && !time.Now().Before(w.nextRotate)
+}
+
+func (w *rotateWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	w.nextRotate = nextRotateTime(time.Now(), w.cfg.Interval)
+	return nil
+}
+
+func (w *rotateWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	if err := os.Rename(w.path, w.backupName(time.Now())); err != nil {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.mill()
+	return nil
+}
+
+func (w *rotateWriter) backupName(t time.Time) string {
+	ext := filepath.Ext(w.path)
+	prefix := strings.TrimSuffix(w.path, ext)
+	return fmt.Sprintf("%s-%s%s", prefix, t.Format(backupTimeFormat), ext)
+}
+
+// 通知后台协程压缩和清理历史文件
+func (w *rotateWriter) mill() {
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+}
+
+func (w *rotateWriter) millRun() {
+	for range w.millCh {
+		if err := w.millOnce(); err != nil {
+			fmt.Fprintf(os.Stderr, "zlog rotate mill error: %v\n", err)
+		}
+	}
+}
+
+type backupFile struct {
+	path string
+	time time.Time
+}
+
+func (w *rotateWriter) millOnce() error {
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+	var remove []backupFile
+	if w.cfg.MaxBackups > 0 && len(backups) > w.cfg.MaxBackups {
+		remove = append(remove, backups[w.cfg.MaxBackups:]...)
+		backups = backups[:w.cfg.MaxBackups]
+	}
+	if w.cfg.MaxAge > 0 {
+		cutoff := time.Now().Add(-w.cfg.MaxAge)
+		var keep []backupFile
+		for _, b := range backups {
+			if b.time.Before(cutoff) {
+				remove = append(remove, b)
+			} else {
+				keep = append(keep, b)
+			}
+		}
+		backups = keep
+	}
+	for _, b := range remove {
+		if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if !w.cfg.Compress {
+		return nil
+	}
+	for _, b := range backups {
+		if strings.HasSuffix(b.path, compressSuffix) {
+			continue
+		}
+		if err := compressFile(b.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 历史文件列表 按时间从新到旧排序
+func (w *rotateWriter) backups() ([]backupFile, error) {
+	dir := filepath.Dir(w.path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ext := filepath.Ext(w.path)
+	prefix := strings.TrimSuffix(filepath.Base(w.path), ext) + "-"
+	var backups []backupFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimSuffix(name[len(prefix):], compressSuffix), ext)
+		t, err := time.ParseInLocation(backupTimeFormat, ts, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{
+			path: filepath.Join(dir, name),
+			time: t,
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].time.After(backups[j].time)
+	})
+	return backups, nil
+}
+
+func compressFile(src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(src+compressSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// 下一次按时间滚动的时间点, 不按时间滚动返回零值
+func nextRotateTime(now time.Time, interval string) time.Time {
+	switch interval {
+	case "hourly":
+		return now.Truncate(time.Hour).Add(time.Hour)
+	case "daily":
+		y, m, d := now.Date()
+		return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+	default:
+		return time.Time{}
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package zlog
+
+import (
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/tnnmigga/core/infra/process"
+)
+
+var handleSignals sync.Once
+
+// 监听信号调整日志等级 由Server启动时调用, 单独使用zlog时不会占用信号
+// SIGUSR1 切换到debug等级
+// SIGUSR2 恢复为配置的等级
+func HandleSignals() {
+	handleSignals.Do(func() {
+		process.OnSignal(onLevelSignal, syscall.SIGUSR1, syscall.SIGUSR2)
+	})
+}
+
+func onLevelSignal(sig os.Signal) {
+	var err error
+	if sig == syscall.SIGUSR1 {
+		err = SetLevel("debug")
+	} else {
+		err = ResetLevel()
+	}
+	if err != nil {
+		Errorf("zlog change level by signal error %v", err)
+		return
+	}
+	Warnf("zlog level changed to %s by signal %v", Level(), sig)
+}
@@ -0,0 +1,6 @@
+//go:build windows
+
+package zlog
+
+// windows没有SIGUSR1/SIGUSR2
+func HandleSignals() {}
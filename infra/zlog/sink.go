@@ -0,0 +1,144 @@
+package zlog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tnnmigga/core/conf"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	writers   = map[string]*rotateWriter{} // 同一个文件只打开一次, 重新Init时复用
+	writersMu sync.Mutex
+)
+
+// 日志输出目标
+// path为stdout/stderr或文件路径
+// 每个输出目标有独立的最低等级和编码方式, 同时受全局等级控制
+type sinkConfig struct {
+	Path     string
+	Level    zapcore.Level
+	Encoding string // console/json
+	Rotate   rotateConfig
+}
+
+// 读取输出目标配置
+// 未配置zlog.sinks时使用zlog.stdout作为唯一的输出目标, 编码读取zlog.encoding, 滚动配置读取zlog.rotate
+//
+//	"zlog": {
+//	    "sinks": [
+//	        {"path": "stdout", "level": "debug", "encoding": "console"},
+//	        {"path": "logs/error.log", "level": "error", "encoding": "json",
+//	         "max-size": 100, "interval": "daily", "max-backups": 30, "max-age": 7, "compress": true}
+//	    ]
+//	}
+func loadSinks() ([]*sinkConfig, error) {
+	items := conf.Array[map[string]any]("zlog.sinks", nil)
+	if len(items) == 0 {
+		legacy := map[string]any{}
+		if rotate, ok := conf.Any[map[string]any]("zlog.rotate"); ok {
+			for k, v := range rotate {
+				legacy[k] = v
+			}
+		}
+		items = []map[string]any{legacy}
+		items[0]["path"] = conf.String("zlog.stdout", "stdout")
+		items[0]["encoding"] = conf.String("zlog.encoding", conf.String("log.encoding", "console")) // log.encoding为兼容旧配置
+	}
+	sinks := make([]*sinkConfig, 0, len(items))
+	for _, item := range items {
+		sink, err := parseSink(item)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+func parseSink(item map[string]any) (*sinkConfig, error) {
+	sink := &sinkConfig{
+		Path:     mapValue(item, "path", "stdout"),
+		Encoding: mapValue(item, "encoding", "console"),
+		Rotate: rotateConfig{
+			MaxSize:    int64(mapValue(item, "max-size", 0.0) * (1 << 20)), // MB
+			Interval:   mapValue(item, "interval", ""),
+			MaxBackups: int(mapValue(item, "max-backups", 0.0)),
+			MaxAge:     time.Duration(mapValue(item, "max-age", 0.0) * float64(24*time.Hour)), // 天
+			Compress:   mapValue(item, "compress", false),
+		},
+	}
+	if err := sink.Level.UnmarshalText([]byte(mapValue(item, "level", "debug"))); err != nil {
+		return nil, fmt.Errorf("zlog sink %s level error: %v", sink.Path, err)
+	}
+	if sink.Encoding != "console" && sink.Encoding != "json" {
+		return nil, fmt.Errorf("zlog sink %s encoding error: %s", sink.Path, sink.Encoding)
+	}
+	switch sink.Rotate.Interval {
+	case "", "hourly", "daily":
+	default:
+		return nil, fmt.Errorf("zlog sink %s interval error: %s", sink.Path, sink.Rotate.Interval)
+	}
+	return sink, nil
+}
+
+func mapValue[T any](m map[string]any, key string, defaultVal T) T {
+	if v, ok := m[key].(T); ok {
+		return v
+	}
+	return defaultVal
+}
+
+func newEncoderConfig() zapcore.EncoderConfig {
+	cfg := zap.NewProductionEncoderConfig()
+	cfg.EncodeTime = func(t time.Time, encoder zapcore.PrimitiveArrayEncoder) {
+		encoder.AppendString(t.Format("2006-01-02 15:04:05.000000"))
+	}
+	cfg.EncodeCaller = func(caller zapcore.EntryCaller, encoder zapcore.PrimitiveArrayEncoder) {
+		index := strings.LastIndex(caller.Function, "/")
+		encoder.AppendString(fmt.Sprintf("%s:%d", caller.Function[index+1:], caller.Line))
+	}
+	return cfg
+}
+
+func newCore(sink *sinkConfig, globalLevel zap.AtomicLevel) (zapcore.Core, error) {
+	var encoder zapcore.Encoder
+	if sink.Encoding == "json" {
+		encoder = zapcore.NewJSONEncoder(newEncoderConfig())
+	} else {
+		encoder = zapcore.NewConsoleEncoder(newEncoderConfig())
+	}
+	ws, err := openSink(sink)
+	if err != nil {
+		return nil, err
+	}
+	enabler := zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+		return l >= sink.Level && globalLevel.Enabled(l)
+	})
+	return zapcore.NewCore(encoder, ws, enabler), nil
+}
+
+func openSink(sink *sinkConfig) (zapcore.WriteSyncer, error) {
+	switch sink.Path {
+	case "stdout":
+		return zapcore.Lock(os.Stdout), nil
+	case "stderr":
+		return zapcore.Lock(os.Stderr), nil
+	}
+	writersMu.Lock()
+	defer writersMu.Unlock()
+	if w, ok := writers[sink.Path]; ok {
+		return w, nil
+	}
+	w, err := newRotateWriter(sink.Path, sink.Rotate)
+	if err != nil {
+		return nil, err
+	}
+	writers[sink.Path] = w
+	return w, nil
+}
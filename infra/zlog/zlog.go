@@ -2,8 +2,6 @@ package zlog
 
 import (
 	"fmt"
-	"strings"
-	"time"
 
 	"github.com/tnnmigga/core/conf"
 	"github.com/tnnmigga/core/infra/process"
@@ -11,7 +9,12 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-var logger *zap.SugaredLogger
+var (
+	base      *zap.Logger // 不跳过调用栈 用于派生子日志
+	logger    *zap.SugaredLogger
+	level     zap.AtomicLevel
+	initLevel string // 配置的日志等级
+)
 
 func init() {
 	// 先按默认值临时创建一个logger
@@ -19,39 +22,66 @@ func init() {
 }
 
 func Init() {
-	var logLevel zap.AtomicLevel
-	err := logLevel.UnmarshalText([]byte(conf.String("zlog.level", "debug")))
+	logLevel := zap.NewAtomicLevel()
+	initLevel = conf.String("zlog.level", "debug")
+	err := logLevel.UnmarshalText([]byte(initLevel))
 	if err != nil {
 		Fatal(fmt.Errorf("log Init level error: %v", err))
 	}
-	conf := zap.Config{
-		Level:             logLevel,
-		Development:       false,
-		Encoding:          conf.String("log.encoding", "console"),
-		EncoderConfig:     zap.NewProductionEncoderConfig(),
-		OutputPaths:       []string{conf.String("zlog.stdout", "stdout")},
-		ErrorOutputPaths:  []string{conf.String("zlog.stderr", "stderr")},
-		DisableCaller:     false,
-		DisableStacktrace: true,
-	}
-	conf.EncoderConfig.EncodeTime = func(t time.Time, encoder zapcore.PrimitiveArrayEncoder) {
-		encoder.AppendString(t.Format("2006-01-02 15:04:05.000000"))
+	level = logLevel
+	sinks, err := loadSinks()
+	if err != nil {
+		panic(fmt.Errorf("zlog Init sinks error: %v", err))
 	}
-	conf.EncoderConfig.EncodeCaller = func(caller zapcore.EntryCaller, encoder zapcore.PrimitiveArrayEncoder) {
-		index := strings.LastIndex(caller.Function, "/")
-		encoder.AppendString(fmt.Sprintf("%s:%d", caller.Function[index+1:], caller.Line))
+	cores := make([]zapcore.Core, 0, len(sinks))
+	for _, sink := range sinks {
+		core, err := newCore(sink, logLevel)
+		if err != nil {
+			panic(fmt.Errorf("zlog Init open sink %s error: %v", sink.Path, err))
+		}
+		cores = append(cores, core)
 	}
-	l, err := conf.Build(zap.AddCallerSkip(1))
+	// zap内部错误输出
+	errOutput, _, err := zap.Open(conf.String("zlog.stderr", "stderr"))
 	if err != nil {
-		Fatal(fmt.Errorf("zlog Init conf build error: %v", err))
+		panic(fmt.Errorf("zlog Init open stderr error: %v", err))
 	}
-	logger = l.Sugar()
+	l := zap.New(zapcore.NewTee(cores...), zap.AddCaller(), zap.ErrorOutput(errOutput))
+	base = l
+	logger = l.WithOptions(zap.AddCallerSkip(1)).Sugar()
 }
 
 func Logger() *zap.SugaredLogger {
 	return logger
 }
 
+// 运行时修改日志等级
+func SetLevel(text string) error {
+	return level.UnmarshalText([]byte(text))
+}
+
+// 当前日志等级
+func Level() string {
+	return level.String()
+}
+
+// 恢复为配置的日志等级
+func ResetLevel() error {
+	return SetLevel(initLevel)
+}
+
+// 创建携带固定字段的子日志
+// args为交替的key和value, 如 zlog.With("uid", uid)
+func With(args ...any) *zap.SugaredLogger {
+	return base.Sugar().With(args...)
+}
+
+// 创建模块专用的子日志 自动携带module和server_id字段
+// 日志等级与全局共享, 但重新调用Init后需要重新创建
+func Named(module string) *zap.SugaredLogger {
+	return base.Sugar().With("module", module, "server_id", conf.ServerID)
+}
+
 func Debug(args ...any) {
 	logger.Debug(args...)
 }
@@ -60,6 +90,11 @@ func Debugf(format string, args ...any) {
 	logger.Debugf(format, args...)
 }
 
+// 结构化日志 keysAndValues为交替的key和value
+func Debugw(msg string, keysAndValues ...any) {
+	logger.Debugw(msg, keysAndValues...)
+}
+
 func Info(args ...any) {
 	logger.Info(args...)
 }
@@ -68,6 +103,11 @@ func Infof(format string, args ...any) {
 	logger.Infof(format, args...)
 }
 
+// 结构化日志 keysAndValues为交替的key和value
+func Infow(msg string, keysAndValues ...any) {
+	logger.Infow(msg, keysAndValues...)
+}
+
 func Warn(args ...any) {
 	logger.Warn(args...)
 }
@@ -76,6 +116,11 @@ func Warnf(format string, args ...any) {
 	logger.Warnf(format, args...)
 }
 
+// 结构化日志 keysAndValues为交替的key和value
+func Warnw(msg string, keysAndValues ...any) {
+	logger.Warnw(msg, keysAndValues...)
+}
+
 func Error(args ...any) {
 	logger.Error(args...)
 }
@@ -84,6 +129,11 @@ func Errorf(format string, args ...any) {
 	logger.Errorf(format, args...)
 }
 
+// 结构化日志 keysAndValues为交替的key和value
+func Errorw(msg string, keysAndValues ...any) {
+	logger.Errorw(msg, keysAndValues...)
+}
+
 func Panic(args ...any) {
 	logger.Panic(args...)
 }
@@ -32,5 +32,6 @@ func (m *Module) onRPCResponse(req *idef.RPCResponse) {
 }
 
 func (m *Module) onAsyncContext(req *asyncContext) {
+	defer m.asyncs.Add(-1)
 	req.cb(req.res, req.err)
 }
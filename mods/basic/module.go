@@ -2,12 +2,14 @@ package basic
 
 import (
 	"reflect"
+	"sync/atomic"
 
 	"github.com/tnnmigga/core/conc"
 	"github.com/tnnmigga/core/idef"
 	"github.com/tnnmigga/core/infra/zlog"
 	"github.com/tnnmigga/core/msgbus"
 	"github.com/tnnmigga/core/utils"
+	"go.uber.org/zap"
 )
 
 const (
@@ -20,6 +22,10 @@ type Module struct {
 	handlers  map[reflect.Type]any
 	hooks     [idef.ServerStateExit + 1][2][]func() error
 	closeSign chan struct{}
+	depends   []idef.ModName
+	inflight  atomic.Int32 // 已投递但未处理完的消息数量 包括队列中和正在处理的
+	asyncs    atomic.Int32 // 未完成的异步调用数量
+	log       *zap.SugaredLogger
 }
 
 func New(name idef.ModName, mqLen int32) *Module {
@@ -28,6 +34,7 @@ func New(name idef.ModName, mqLen int32) *Module {
 		mq:        make(chan any, mqLen),
 		handlers:  map[reflect.Type]any{},
 		closeSign: make(chan struct{}, 1),
+		log:       zlog.Named(string(name)),
 	}
 	msgbus.RegisterHandler(m, m.onRPCRequest)
 	msgbus.RegisterHandler(m, m.onRPCResponse)
@@ -39,15 +46,50 @@ func (m *Module) Name() idef.ModName {
 	return m.name
 }
 
+// 模块专用日志 自动携带module和server_id字段
+func (m *Module) Logger() *zap.SugaredLogger {
+	return m.log
+}
+
 func (m *Module) MQ() chan any {
 	return m.mq
 }
 
 func (m *Module) Assign(msg any) {
+	m.TryAssign(msg)
+}
+
+// 投递消息 队列已满或模块已停止时丢弃并返回false
+// 调用方可据此释放为该消息持有的资源
+// 投递前计数 保证从出队到处理完成之间也不会被视为空闲
+func (m *Module) TryAssign(msg any) (ok bool) {
+	m.inflight.Add(1)
+	defer func() {
+		if r := recover(); r != nil {
+			// 模块已停止 队列已关闭
+			m.drop(msg)
+			zlog.LimitErrorf("modele %s stopped, lose %s", m.name, utils.Lazy{V: msg})
+			ok = false
+		}
+	}()
 	select {
 	case m.mq <- msg:
+		return true
 	default:
-		zlog.Errorf("modele %s mq full, lose %s", m.name, utils.String(msg))
+		m.drop(msg)
+		zlog.LimitErrorf("modele %s mq full, lose %s", m.name, utils.Lazy{V: msg})
+		return false
+	}
+}
+
+// 丢弃的消息不会再回调 需要修正相应的计数
+func (m *Module) drop(msg any) {
+	m.inflight.Add(-1)
+	switch msg.(type) {
+	case *asyncContext:
+		m.asyncs.Add(-1)
+	case *idef.RPCResponse:
+		msgbus.DropRPC()
 	}
 }
 
@@ -60,6 +102,31 @@ func (m *Module) RegisterHandler(mType reflect.Type, handler any) {
 	m.handlers[mType] = handler
 }
 
+// 声明依赖的模块
+// 被依赖的模块先于本模块初始化和运行, 并在本模块停止后再停止
+func (m *Module) DependOn(names ...idef.ModName) {
+	m.depends = append(m.depends, names...)
+}
+
+func (m *Module) Depends() []idef.ModName {
+	return m.depends
+}
+
+// 未完成的工作: 待处理消息/正在处理的消息/未回调的异步调用
+func (m *Module) Inflight() map[string]int {
+	// 先读计数再读队列长度, 计数不为0时mq和handling至少一项不为0
+	inflight := int(m.inflight.Load())
+	queued := len(m.mq)
+	res := map[string]int{
+		"mq":    queued,
+		"async": int(m.asyncs.Load()),
+	}
+	if inflight > queued {
+		res["handling"] = inflight - queued
+	}
+	return res
+}
+
 func (m *Module) Hook(state idef.ServerState, stage int) []func() error {
 	return m.hooks[state][stage]
 }
@@ -80,16 +147,17 @@ func (m *Module) After(state idef.ServerState, hook func() error) {
 
 func (m *Module) Run() {
 	defer func() {
-		zlog.Infof("%v has stoped", m.Name())
+		m.log.Infof("%v has stoped", m.Name())
 		m.closeSign <- struct{}{}
 	}()
 	for msg := range m.mq {
 		m.cb(msg)
+		m.inflight.Add(-1)
 	}
 }
 
 func (m *Module) Stop() {
-	zlog.Infof("try stop %s", m.name)
+	m.log.Infof("try stop %s", m.name)
 	close(m.mq)
 	<-m.closeSign
 }
@@ -99,7 +167,7 @@ func (m *Module) cb(msg any) {
 	msgType := reflect.TypeOf(msg)
 	h, ok := m.handlers[msgType]
 	if !ok {
-		zlog.Errorf("handler not exist %v", msgType)
+		zlog.LimitErrorf("handler not exist %v", msgType)
 		return
 	}
 	fn, ok := h.(func(any))
@@ -124,6 +192,7 @@ func (c *asyncContext) DeepCopy() interface{} {
 // 执行完将结果返到模块线程往后执行
 // 匿名函数捕获的变量需要防范并发读写问题
 func (m *Module) Async(f func() (any, error), cb func(any, error)) {
+	m.asyncs.Add(1)
 	conc.Go(func() {
 		res, err := f()
 		m.Assign(&asyncContext{
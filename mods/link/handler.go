@@ -95,7 +95,7 @@ func (m *module) onRPContext(ctx *idef.RPCContext) {
 		}
 		rpcResp := data.(*RPCResult)
 		if len(rpcResp.Err) != 0 {
-			resp.Err = errors.New(rpcResp.Err)
+			resp.Err = msgbus.CodeError(rpcResp.ErrCode, rpcResp.Err)
 			return
 		}
 		resp.Err = codec.Unmarshal(rpcResp.Data, resp.Resp)
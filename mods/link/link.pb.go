@@ -24,8 +24,9 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type RPCResult struct {
-	Data []byte `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
-	Err  string `protobuf:"bytes,2,opt,name=Err,proto3" json:"Err,omitempty"`
+	Data    []byte `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
+	Err     string `protobuf:"bytes,2,opt,name=Err,proto3" json:"Err,omitempty"`
+	ErrCode string `protobuf:"bytes,3,opt,name=ErrCode,proto3" json:"ErrCode,omitempty"`
 }
 
 func (m *RPCResult) Reset()         { *m = RPCResult{} }
@@ -75,6 +76,13 @@ func (m *RPCResult) GetErr() string {
 	return ""
 }
 
+func (m *RPCResult) GetErrCode() string {
+	if m != nil {
+		return m.ErrCode
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*RPCResult)(nil), "pb.RPCResult")
 }
@@ -82,19 +90,20 @@ func init() {
 func init() { proto.RegisterFile("core/infra/link/link.proto", fileDescriptor_7c7b77fd2af1aa06) }
 
 var fileDescriptor_7c7b77fd2af1aa06 = []byte{
-	// 181 bytes of a gzipped FileDescriptorProto
+	// 194 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x92, 0x4a, 0xce, 0x2f, 0x4a,
 	0xd5, 0xcf, 0xcc, 0x4b, 0x2b, 0x4a, 0xd4, 0xcf, 0xc9, 0xcc, 0xcb, 0x06, 0x13, 0x7a, 0x05, 0x45,
 	0xf9, 0x25, 0xf9, 0x42, 0x4c, 0x05, 0x49, 0x52, 0x26, 0x65, 0xa9, 0x79, 0x29, 0xf9, 0x45, 0xfa,
 	0xe9, 0x99, 0x25, 0x19, 0xa5, 0x49, 0x7a, 0xc9, 0xf9, 0xb9, 0xfa, 0xe9, 0xf9, 0xe9, 0xf9, 0xfa,
-	0x60, 0x15, 0x49, 0xa5, 0x69, 0x60, 0x1e, 0x98, 0x03, 0x66, 0x41, 0x74, 0x2a, 0x19, 0x72, 0x71,
+	0x60, 0x15, 0x49, 0xa5, 0x69, 0x60, 0x1e, 0x98, 0x03, 0x66, 0x41, 0x74, 0x2a, 0x79, 0x73, 0x71,
 	0x06, 0x05, 0x38, 0x07, 0xa5, 0x16, 0x97, 0xe6, 0x94, 0x08, 0x09, 0x71, 0xb1, 0xb8, 0x24, 0x96,
 	0x24, 0x4a, 0x30, 0x2a, 0x30, 0x6a, 0xf0, 0x04, 0x81, 0xd9, 0x42, 0x02, 0x5c, 0xcc, 0xae, 0x45,
-	0x45, 0x12, 0x4c, 0x0a, 0x8c, 0x1a, 0x9c, 0x41, 0x20, 0xa6, 0x93, 0xda, 0x85, 0x87, 0x72, 0x0c,
-	0x27, 0x1e, 0xc9, 0x31, 0x5e, 0x78, 0x24, 0xc7, 0xf8, 0xe0, 0x91, 0x1c, 0xe3, 0x84, 0xc7, 0x72,
-	0x0c, 0x33, 0x1e, 0xcb, 0x31, 0x5c, 0x78, 0x2c, 0xc7, 0x70, 0xe3, 0xb1, 0x1c, 0x43, 0x14, 0x0b,
-	0xc8, 0x69, 0x49, 0x6c, 0x60, 0x1b, 0x8c, 0x01, 0x01, 0x00, 0x00, 0xff, 0xff, 0x33, 0xa7, 0x25,
-	0xf4, 0xb9, 0x00, 0x00, 0x00,
+	0x45, 0x12, 0x4c, 0x0a, 0x8c, 0x1a, 0x9c, 0x41, 0x20, 0xa6, 0x90, 0x04, 0x17, 0xbb, 0x6b, 0x51,
+	0x91, 0x73, 0x7e, 0x4a, 0xaa, 0x04, 0x33, 0x58, 0x14, 0xc6, 0x75, 0x52, 0x8b, 0x62, 0x01, 0x39,
+	0xea, 0xc2, 0x43, 0x39, 0x86, 0x13, 0x8f, 0xe4, 0x18, 0x2f, 0x3c, 0x92, 0x63, 0x7c, 0xf0, 0x48,
+	0x8e, 0x71, 0xc2, 0x63, 0x39, 0x86, 0x19, 0x8f, 0xe5, 0x18, 0x2e, 0x3c, 0x96, 0x63, 0xb8, 0xf1,
+	0x58, 0x8e, 0x21, 0x89, 0x0d, 0x6c, 0xb7, 0x31, 0x60, 0x00, 0x0f, 0xf7, 0x99, 0x60, 0xd3, 0x00,
+	0x00, 0x00,
 }
 
 func (m *RPCResult) Marshal() (dAtA []byte, err error) {
@@ -117,6 +126,13 @@ func (m *RPCResult) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.ErrCode) > 0 {
+		i -= len(m.ErrCode)
+		copy(dAtA[i:], m.ErrCode)
+		i = encodeVarintLink(dAtA, i, uint64(len(m.ErrCode)))
+		i--
+		dAtA[i] = 0x1a
+	}
 	if len(m.Err) > 0 {
 		i -= len(m.Err)
 		copy(dAtA[i:], m.Err)
@@ -159,6 +175,10 @@ func (m *RPCResult) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovLink(uint64(l))
 	}
+	l = len(m.ErrCode)
+	if l > 0 {
+		n += 1 + l + sovLink(uint64(l))
+	}
 	return n
 }
 
@@ -263,6 +283,38 @@ func (m *RPCResult) Unmarshal(dAtA []byte) error {
 			}
 			m.Err = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ErrCode", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowLink
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthLink
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthLink
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ErrCode = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipLink(dAtA[iNdEx:])
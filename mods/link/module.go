@@ -2,8 +2,11 @@ package link
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tnnmigga/core/codec"
@@ -24,12 +27,14 @@ const (
 
 type module struct {
 	*basic.Module
-	conn    *nats.Conn
-	js      jetstream.JetStream
-	stream  jetstream.Stream
-	cons    jetstream.Consumer
-	consCtx jetstream.ConsumeContext
-	subs    [5]*nats.Subscription
+	conn      *nats.Conn
+	connected atomic.Bool // 已连接 之后conn不再修改
+	js        jetstream.JetStream
+	stream    jetstream.Stream
+	cons      jetstream.Consumer
+	consCtx   jetstream.ConsumeContext
+	subs      [5]*nats.Subscription
+	closed    sync.Once
 }
 
 func New() idef.IModule {
@@ -59,6 +64,7 @@ func (m *module) afterInit() error {
 		return err
 	}
 	m.conn = conn
+	m.connected.Store(true)
 	m.js, err = jetstream.New(m.conn)
 	if err != nil {
 		return err
@@ -130,19 +136,50 @@ func randomRpcSubject(serverType string) string {
 }
 
 func (m *module) beforeStop() error {
-	m.consCtx.Stop()
-	for _, sub := range m.subs {
-		sub.Drain()
-	}
+	return m.CloseIntake()
+}
+
+// 停止从nats接收消息
+// 停止流程最先调用, 之后不会再有外部消息进入本进程
+func (m *module) CloseIntake() error {
+	m.closed.Do(func() {
+		if m.consCtx != nil {
+			m.consCtx.Stop()
+		}
+		for _, sub := range m.subs {
+			if sub != nil {
+				sub.Drain()
+			}
+		}
+	})
 	return nil
 }
 
+// 在模块统计的基础上增加未确认的异步stream投递
+func (m *module) Inflight() map[string]int {
+	res := m.Module.Inflight()
+	if m.js != nil {
+		res["stream-publish"] = m.js.PublishAsyncPending()
+	}
+	return res
+}
+
 func (m *module) afterStop() error {
 	<-m.js.PublishAsyncComplete()
 	m.conn.Close()
 	return nil
 }
 
+func (m *module) HealthCheck() error {
+	if !m.connected.Load() {
+		return errors.New("nats not connected")
+	}
+	if !m.conn.IsConnected() {
+		return fmt.Errorf("nats status %s", m.conn.Status())
+	}
+	return nil
+}
+
 func (m *module) streamMsgHandler(msg jetstream.Msg) {
 	defer utils.RecoverPanic()
 	msg.Ack()
@@ -158,7 +195,7 @@ func (m *module) streamMsgHandler(msg jetstream.Msg) {
 	}
 	pkg, err := codec.Decode(msg.Data())
 	if err != nil {
-		zlog.Errorf("nats streamRecv decode msg error: %v", err)
+		zlog.LimitErrorf("nats streamRecv decode msg error: %v", err)
 		return
 	}
 	msgbus.Cast(pkg)
@@ -168,7 +205,7 @@ func (m *module) msgHandler(msg *nats.Msg) {
 	defer utils.RecoverPanic()
 	pkg, err := codec.Decode(msg.Data)
 	if err != nil {
-		zlog.Errorf("nats recv decode msg error: %v", err)
+		zlog.LimitErrorf("nats recv decode msg error: %v", err)
 		return
 	}
 	msgbus.Cast(pkg)
@@ -186,6 +223,7 @@ func (m *module) rpcHandler(msg *nats.Msg) {
 	msgbus.RPC(m, msgbus.Local(), req, func(resp any, err error) {
 		if err != nil {
 			rpcResp.Err = err.Error()
+			rpcResp.ErrCode = msgbus.ErrorCode(err)
 		} else {
 			rpcResp.Data = codec.Marshal(resp)
 		}
@@ -3,12 +3,9 @@ package mongo
 import (
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-)
+	"github.com/tnnmigga/core/idef"
 
-var (
-	ErrNoDocuments = mongo.ErrNoDocuments
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 const (
@@ -22,27 +19,76 @@ type MongoSaveOp struct {
 
 // 保存至MongoDB
 // GroupKey为保证并发时的时序
+// 暂时性错误按退避重试, 仍失败时写入本地预写日志, 下次启动时重放
+// Notify不为空时写入失败会向本进程该模块投递MongoWriteFailed
 type MongoSaveSingle struct {
 	GroupKey string
 	CollName string
 	Op       *MongoSaveOp
+	Notify   idef.ModName
 }
 
 // 保存至MongoDB
 // GroupKey为保证并发时的时序
+// 暂时性错误按退避重试, 仍失败时写入本地预写日志, 下次启动时重放
+// Notify不为空时写入失败会向本进程该模块投递MongoWriteFailed
 type MongoSaveMulti struct {
 	GroupKey string
 	CollName string
 	Ops      []*MongoSaveOp
+	Notify   idef.ModName
+}
+
+// 保存至MongoDB并等待结果
+// GroupKey为保证并发时的时序
+// 暂时性错误按退避重试, 仍失败时返回错误, 不写入预写日志, 由调用方处理
+// 返回*MongoResult
+type MongoSaveWithAck struct {
+	GroupKey string
+	CollName string
+	Ops      []*MongoSaveOp
+}
+
+// 写入失败通知
+type MongoWriteFailed struct {
+	CollName string
+	Ops      []*MongoSaveOp
+	Err      string
 }
 
 // 从MongoDB加载数据
 // GroupKey为保证并发时的时序
+// Limit为0时结果超过mongo.max-results返回ErrTooManyResults, 大于该值时按该值截断
 // 返回[]bson.Raw
 type MongoLoadMulti struct {
-	GroupKey string
-	CollName string
-	Filter   bson.M
+	GroupKey   string
+	CollName   string
+	Filter     bson.M
+	Projection bson.M // 只返回的字段 如{"name": 1}
+	Sort       bson.D // 有序 如{{"level", -1}, {"_id", 1}}
+	Skip       int64
+	Limit      int64
+}
+
+// 分页加载 基于排序字段的游标, 翻页开销与页码无关
+// SortKey默认为_id, 不唯一时以_id作为第二排序字段
+// Token为空时从第一页开始, 返回的Next为空表示没有更多数据
+// GroupKey为保证并发时的时序
+// 返回*MongoPage
+type MongoLoadPage struct {
+	GroupKey   string
+	CollName   string
+	Filter     bson.M
+	Projection bson.M
+	SortKey    string
+	Desc       bool
+	PageSize   int64
+	Token      string
+}
+
+type MongoPage struct {
+	Docs []bson.Raw
+	Next string
 }
 
 // 从MongoDB加载数据
@@ -53,3 +99,130 @@ type MongoLoadSingle struct {
 	CollName string
 	Filter   bson.M
 }
+
+// 替换单个文档
+// Upsert为true时不存在则插入
+// GroupKey为保证并发时的时序
+// 返回*MongoResult
+type MongoReplaceOne struct {
+	GroupKey string
+	CollName string
+	Filter   bson.M
+	Value    []byte // Value必须是bson序列化好的二进制数据
+	Upsert   bool
+}
+
+// 删除单个文档
+// GroupKey为保证并发时的时序
+// 返回*MongoResult
+type MongoDeleteOne struct {
+	GroupKey string
+	CollName string
+	Filter   bson.M
+}
+
+// 删除所有匹配的文档
+// GroupKey为保证并发时的时序
+// 返回*MongoResult
+type MongoDeleteMany struct {
+	GroupKey string
+	CollName string
+	Filter   bson.M
+}
+
+// 更新单个文档 Update为$set/$inc等更新操作符
+// GroupKey为保证并发时的时序
+// 返回*MongoResult
+type MongoUpdateOne struct {
+	GroupKey string
+	CollName string
+	Filter   bson.M
+	Update   bson.M
+	Upsert   bool
+}
+
+// 更新所有匹配的文档
+// GroupKey为保证并发时的时序
+// 返回*MongoResult
+type MongoUpdateMany struct {
+	GroupKey string
+	CollName string
+	Filter   bson.M
+	Update   bson.M
+	Upsert   bool
+}
+
+// 原子地更新并返回文档 可用于计数器等
+// ReturnNew为true时返回更新后的文档
+// GroupKey为保证并发时的时序
+// 返回bson.Raw, 未匹配且不upsert时返回ErrNoDocuments
+type MongoFindOneAndUpdate struct {
+	GroupKey  string
+	CollName  string
+	Filter    bson.M
+	Update    bson.M
+	Upsert    bool
+	ReturnNew bool
+}
+
+// 聚合查询
+// GroupKey为保证并发时的时序
+// 返回[]bson.Raw
+type MongoAggregate struct {
+	GroupKey string
+	CollName string
+	Pipeline []bson.M
+}
+
+// 统计匹配的文档数量
+// GroupKey为保证并发时的时序
+// 返回*MongoCountResult
+type MongoCount struct {
+	GroupKey string
+	CollName string
+	Filter   bson.M
+}
+
+type MongoCountResult struct {
+	Count int64
+}
+
+// 事务中的单个写操作
+const (
+	MongoTxInsert  = "insert"
+	MongoTxReplace = "replace"
+	MongoTxUpdate  = "update"
+	MongoTxDelete  = "delete"
+)
+
+type MongoTxOp struct {
+	Type     string // MongoTxInsert等
+	CollName string
+	Filter   bson.M
+	Value    []byte // insert/replace时为bson序列化好的文档
+	Update   bson.M // update时的更新操作符
+	Upsert   bool
+	Many     bool // update/delete是否作用于所有匹配的文档
+}
+
+// 多文档事务 所有操作全部成功或全部回滚
+// 需要mongo以副本集或分片集群部署
+// GroupKey为保证并发时的时序
+// 返回*MongoTxResult
+type MongoTransaction struct {
+	GroupKey string
+	Ops      []*MongoTxOp
+}
+
+type MongoTxResult struct {
+	Results []*MongoResult
+}
+
+// 写操作结果
+type MongoResult struct {
+	InsertedCount int64
+	MatchedCount  int64
+	ModifiedCount int64
+	UpsertedCount int64
+	DeletedCount  int64
+}
@@ -0,0 +1,59 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tnnmigga/core/msgbus"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 调用结果中的错误类型 通过errors.Is判断
+// 跨进程调用时同样可以判断
+var (
+	ErrNoDocuments    = mongo.ErrNoDocuments
+	ErrDuplicateKey   = errors.New("mongo duplicate key")
+	ErrWriteConflict  = errors.New("mongo write conflict")
+	ErrTimeout        = errors.New("mongo timeout")
+	ErrNetwork        = errors.New("mongo network error")
+	ErrInvalidRequest = errors.New("mongo invalid request")
+	ErrTooManyResults = errors.New("mongo too many results")
+	ErrInvalidToken   = errors.New("mongo invalid page token")
+)
+
+func init() {
+	msgbus.RegisterError("mongo.no-documents", ErrNoDocuments)
+	msgbus.RegisterError("mongo.duplicate-key", ErrDuplicateKey)
+	msgbus.RegisterError("mongo.write-conflict", ErrWriteConflict)
+	msgbus.RegisterError("mongo.timeout", ErrTimeout)
+	msgbus.RegisterError("mongo.network", ErrNetwork)
+	msgbus.RegisterError("mongo.invalid-request", ErrInvalidRequest)
+	msgbus.RegisterError("mongo.too-many-results", ErrTooManyResults)
+	msgbus.RegisterError("mongo.invalid-token", ErrInvalidToken)
+}
+
+const codeWriteConflict = 112
+
+// 将驱动错误归类为上面的错误类型
+func wrapError(err error) error {
+	switch {
+	case err == nil || errors.Is(err, mongo.ErrNoDocuments):
+		return err
+	case mongo.IsDuplicateKeyError(err):
+		return fmt.Errorf("%w: %v", ErrDuplicateKey, err)
+	case mongo.IsTimeout(err) || errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	case mongo.IsNetworkError(err):
+		return fmt.Errorf("%w: %v", ErrNetwork, err)
+	case hasErrorCode(err, codeWriteConflict):
+		return fmt.Errorf("%w: %v", ErrWriteConflict, err)
+	}
+	return err
+}
+
+func hasErrorCode(err error, code int) bool {
+	var se mongo.ServerError
+	return errors.As(err, &se) && se.HasErrorCode(code)
+}
@@ -2,10 +2,12 @@ package mongo
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/tnnmigga/core/conc"
-	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/idef"
 	"github.com/tnnmigga/core/msgbus"
+	"github.com/tnnmigga/core/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -15,38 +17,48 @@ import (
 func (m *module) registerHandler() {
 	msgbus.RegisterHandler(m, m.onMongoSaveSingle)
 	msgbus.RegisterHandler(m, m.onMongoSaveMulti)
+	msgbus.RegisterRPC(m, m.onMongoSaveWithAck)
 	msgbus.RegisterRPC(m, m.onMongoLoadMulti)
 	msgbus.RegisterRPC(m, m.onMongoLoadSingle)
+	msgbus.RegisterRPC(m, m.onMongoLoadPage)
+	msgbus.RegisterRPC(m, m.onMongoReplaceOne)
+	msgbus.RegisterRPC(m, m.onMongoDeleteOne)
+	msgbus.RegisterRPC(m, m.onMongoDeleteMany)
+	msgbus.RegisterRPC(m, m.onMongoUpdateOne)
+	msgbus.RegisterRPC(m, m.onMongoUpdateMany)
+	msgbus.RegisterRPC(m, m.onMongoFindOneAndUpdate)
+	msgbus.RegisterRPC(m, m.onMongoAggregate)
+	msgbus.RegisterRPC(m, m.onMongoCount)
+	msgbus.RegisterRPC(m, m.onMongoTransaction)
 }
 
 func (m *module) onMongoSaveSingle(req *MongoSaveSingle) {
 	conc.GoWithGroup(req.GroupKey, func() {
 		m.semaphore.P()
 		defer m.semaphore.V()
-		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
-		defer cancel()
-		_, err := m.database.Collection(req.CollName).ReplaceOne(ctx, req.Op.Filter, req.Op.Value, options.Replace().SetUpsert(true))
-		if err != nil {
-			zlog.Errorf("mongo save single error %v", err)
-		}
+		m.persist(req.CollName, []*MongoSaveOp{req.Op}, req.Notify)
 	})
 }
 
 func (m *module) onMongoSaveMulti(req *MongoSaveMulti) {
-	ms := make([]mongo.WriteModel, 0, len(req.Ops))
-	for _, op := range req.Ops {
-		m := mongo.NewReplaceOneModel().SetFilter(op.Filter).SetReplacement(op.Value).SetUpsert(true)
-		ms = append(ms, m)
-	}
 	conc.GoWithGroup(req.GroupKey, func() {
 		m.semaphore.P()
 		defer m.semaphore.V()
-		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
-		defer cancel()
-		_, err := m.database.Collection(req.CollName).BulkWrite(ctx, ms)
+		m.persist(req.CollName, req.Ops, req.Notify)
+	})
+}
+
+func (m *module) onMongoSaveWithAck(req *MongoSaveWithAck, resolve func(any), reject func(error)) {
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		res, err := m.saveWithRetry(req.CollName, req.Ops)
 		if err != nil {
-			zlog.Errorf("mongo save multi error %v", err)
+			reject(wrapError(err))
+			return
 		}
+		m.journal.written(req.CollName, opFilters(req.Ops))
+		resolve(res)
 	})
 }
 
@@ -59,7 +71,7 @@ func (m *module) onMongoLoadSingle(req *MongoLoadSingle, resolve func(any), reje
 		res := m.database.Collection(req.CollName).FindOne(ctx, req.Filter)
 		raw, err := res.Raw()
 		if res.Err() != nil {
-			reject(err)
+			reject(wrapError(err))
 		} else {
 			resolve(raw)
 		}
@@ -72,16 +84,329 @@ func (m *module) onMongoLoadMulti(req *MongoLoadMulti, resolve func(any), reject
 		defer m.semaphore.V()
 		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
 		defer cancel()
-		cur, _ := m.database.Collection(req.CollName).Find(ctx, req.Filter)
+		opts := options.Find().SetSkip(req.Skip)
+		if req.Projection != nil {
+			opts.SetProjection(req.Projection)
+		}
+		if req.Sort != nil {
+			opts.SetSort(req.Sort)
+		}
+		limit := req.Limit
+		if limit <= 0 {
+			limit = m.maxResult + 1 // 多取一个用于判断是否超出
+		}
+		opts.SetLimit(utils.Min(limit, m.maxResult+1))
+		raws, err := m.find(ctx, req.CollName, utils.IfElse(req.Filter != nil, req.Filter, bson.M{}), opts)
+		if err != nil {
+			reject(wrapError(err))
+			return
+		}
+		if int64(len(raws)) > m.maxResult {
+			if req.Limit <= 0 {
+				reject(fmt.Errorf("%w: %s more than %d", ErrTooManyResults, req.CollName, m.maxResult))
+				return
+			}
+			raws = raws[:m.maxResult]
+		}
+		resolve(raws)
+	})
+}
+
+func (m *module) onMongoLoadPage(req *MongoLoadPage, resolve func(any), reject func(error)) {
+	if req.PageSize <= 0 || req.PageSize > m.maxResult {
+		reject(fmt.Errorf("%w: page size %d", ErrInvalidRequest, req.PageSize))
+		return
+	}
+	key := utils.IfElse(req.SortKey != "", req.SortKey, "_id")
+	filter, err := pageFilter(req.Filter, key, req.Desc, req.Token)
+	if err != nil {
+		reject(err)
+		return
+	}
+	order := utils.IfElse(req.Desc, -1, 1)
+	sort := bson.D{{Key: key, Value: order}}
+	if key != "_id" {
+		sort = append(sort, bson.E{Key: "_id", Value: order})
+	}
+	opts := options.Find().SetSort(sort).SetLimit(req.PageSize + 1)
+	if req.Projection != nil {
+		opts.SetProjection(req.Projection)
+	}
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
+		defer cancel()
+		raws, err := m.find(ctx, req.CollName, filter, opts)
+		if err != nil {
+			reject(wrapError(err))
+			return
+		}
+		page := &MongoPage{Docs: raws}
+		if int64(len(raws)) > req.PageSize {
+			page.Docs = raws[:req.PageSize]
+			page.Next, err = pageToken(page.Docs[req.PageSize-1], key)
+			if err != nil {
+				reject(err)
+				return
+			}
+		}
+		resolve(page)
+	})
+}
+
+func (m *module) find(ctx context.Context, collName string, filter any, opts *options.FindOptions) ([]bson.Raw, error) {
+	cur, err := m.database.Collection(collName).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	raws := []bson.Raw{}
+	for cur.Next(ctx) {
+		raws = append(raws, cur.Current)
+	}
+	return raws, cur.Err()
+}
+
+func (m *module) onMongoReplaceOne(req *MongoReplaceOne, resolve func(any), reject func(error)) {
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
+		defer cancel()
+		res, err := m.database.Collection(req.CollName).ReplaceOne(ctx, req.Filter, bson.Raw(req.Value), options.Replace().SetUpsert(req.Upsert))
+		if err != nil {
+			reject(wrapError(err))
+			return
+		}
+		r := updateResult(res)
+		m.written(req.CollName, req.Filter, r)
+		resolve(r)
+	})
+}
+
+func (m *module) onMongoDeleteOne(req *MongoDeleteOne, resolve func(any), reject func(error)) {
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
+		defer cancel()
+		res, err := m.database.Collection(req.CollName).DeleteOne(ctx, req.Filter)
+		if err != nil {
+			reject(wrapError(err))
+			return
+		}
+		r := &MongoResult{DeletedCount: res.DeletedCount}
+		m.written(req.CollName, req.Filter, r)
+		resolve(r)
+	})
+}
+
+func (m *module) onMongoDeleteMany(req *MongoDeleteMany, resolve func(any), reject func(error)) {
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
+		defer cancel()
+		res, err := m.database.Collection(req.CollName).DeleteMany(ctx, req.Filter)
+		if err != nil {
+			reject(wrapError(err))
+			return
+		}
+		resolve(&MongoResult{DeletedCount: res.DeletedCount})
+	})
+}
+
+func (m *module) notifyWriteFailed(notify idef.ModName, collName string, ops []*MongoSaveOp, err error) {
+	if notify == "" {
+		return
+	}
+	msgbus.Cast(&MongoWriteFailed{
+		CollName: collName,
+		Ops:      ops,
+		Err:      wrapError(err).Error(),
+	}, msgbus.OneOfMods(notify))
+}
+
+func (m *module) onMongoUpdateOne(req *MongoUpdateOne, resolve func(any), reject func(error)) {
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
+		defer cancel()
+		res, err := m.database.Collection(req.CollName).UpdateOne(ctx, req.Filter, req.Update, options.Update().SetUpsert(req.Upsert))
+		if err != nil {
+			reject(wrapError(err))
+			return
+		}
+		r := updateResult(res)
+		m.written(req.CollName, req.Filter, r)
+		resolve(r)
+	})
+}
+
+func (m *module) onMongoUpdateMany(req *MongoUpdateMany, resolve func(any), reject func(error)) {
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
+		defer cancel()
+		res, err := m.database.Collection(req.CollName).UpdateMany(ctx, req.Filter, req.Update, options.Update().SetUpsert(req.Upsert))
+		if err != nil {
+			reject(wrapError(err))
+			return
+		}
+		resolve(updateResult(res))
+	})
+}
+
+func (m *module) onMongoFindOneAndUpdate(req *MongoFindOneAndUpdate, resolve func(any), reject func(error)) {
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
+		defer cancel()
+		opts := options.FindOneAndUpdate().SetUpsert(req.Upsert)
+		if req.ReturnNew {
+			opts.SetReturnDocument(options.After)
+		}
+		raw, err := m.database.Collection(req.CollName).FindOneAndUpdate(ctx, req.Filter, req.Update, opts).Raw()
+		if err != nil {
+			reject(wrapError(err))
+			return
+		}
+		m.written(req.CollName, req.Filter, &MongoResult{MatchedCount: 1})
+		resolve(raw)
+	})
+}
+
+func (m *module) onMongoAggregate(req *MongoAggregate, resolve func(any), reject func(error)) {
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
+		defer cancel()
+		cur, err := m.database.Collection(req.CollName).Aggregate(ctx, req.Pipeline)
+		if err != nil {
+			reject(wrapError(err))
+			return
+		}
+		defer cur.Close(ctx)
 		raws := []bson.Raw{}
 		for cur.Next(ctx) {
 			raws = append(raws, cur.Current)
 		}
-		err := cur.Err()
+		if err := cur.Err(); err != nil {
+			reject(wrapError(err))
+			return
+		}
+		resolve(raws)
+	})
+}
+
+func (m *module) onMongoCount(req *MongoCount, resolve func(any), reject func(error)) {
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
+		defer cancel()
+		n, err := m.database.Collection(req.CollName).CountDocuments(ctx, utils.IfElse[any](req.Filter != nil, req.Filter, bson.M{}))
 		if err != nil {
-			reject(err)
-		} else {
-			resolve(raws)
+			reject(wrapError(err))
+			return
+		}
+		resolve(&MongoCountResult{Count: n})
+	})
+}
+
+func (m *module) onMongoTransaction(req *MongoTransaction, resolve func(any), reject func(error)) {
+	if len(req.Ops) == 0 {
+		reject(ErrInvalidRequest)
+		return
+	}
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
+		defer cancel()
+		sess, err := m.mongoCli.StartSession()
+		if err != nil {
+			reject(wrapError(err))
+			return
+		}
+		defer sess.EndSession(ctx)
+		res, err := sess.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+			results := make([]*MongoResult, 0, len(req.Ops))
+			for _, op := range req.Ops {
+				r, err := m.execTxOp(sc, op)
+				if err != nil {
+					return nil, err
+				}
+				results = append(results, r)
+			}
+			return results, nil
+		})
+		if err != nil {
+			reject(wrapError(err))
+			return
 		}
+		results := res.([]*MongoResult)
+		for i, op := range req.Ops {
+			if op.Type != MongoTxInsert && !op.Many {
+				m.written(op.CollName, op.Filter, results[i])
+			}
+		}
+		resolve(&MongoTxResult{Results: results})
 	})
 }
+
+func (m *module) execTxOp(ctx mongo.SessionContext, op *MongoTxOp) (*MongoResult, error) {
+	coll := m.database.Collection(op.CollName)
+	switch op.Type {
+	case MongoTxInsert:
+		_, err := coll.InsertOne(ctx, bson.Raw(op.Value))
+		if err != nil {
+			return nil, err
+		}
+		return &MongoResult{InsertedCount: 1}, nil
+	case MongoTxReplace:
+		res, err := coll.ReplaceOne(ctx, op.Filter, bson.Raw(op.Value), options.Replace().SetUpsert(op.Upsert))
+		if err != nil {
+			return nil, err
+		}
+		return updateResult(res), nil
+	case MongoTxUpdate:
+		var res *mongo.UpdateResult
+		var err error
+		if op.Many {
+			res, err = coll.UpdateMany(ctx, op.Filter, op.Update, options.Update().SetUpsert(op.Upsert))
+		} else {
+			res, err = coll.UpdateOne(ctx, op.Filter, op.Update, options.Update().SetUpsert(op.Upsert))
+		}
+		if err != nil {
+			return nil, err
+		}
+		return updateResult(res), nil
+	case MongoTxDelete:
+		var res *mongo.DeleteResult
+		var err error
+		if op.Many {
+			res, err = coll.DeleteMany(ctx, op.Filter)
+		} else {
+			res, err = coll.DeleteOne(ctx, op.Filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &MongoResult{DeletedCount: res.DeletedCount}, nil
+	}
+	return nil, fmt.Errorf("%w: tx op type %s", ErrInvalidRequest, op.Type)
+}
+
+func updateResult(res *mongo.UpdateResult) *MongoResult {
+	return &MongoResult{
+		MatchedCount:  res.MatchedCount,
+		ModifiedCount: res.ModifiedCount,
+		UpsertedCount: res.UpsertedCount,
+	}
+}
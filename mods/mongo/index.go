@@ -0,0 +1,42 @@
+package mongo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/zlog"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	indexes   = map[idef.ModName]map[string][]mongo.IndexModel{}
+	indexesMu sync.Mutex
+)
+
+// 声明集合索引
+// 需在服务器初始化前声明, 名为mod的mongo模块在初始化阶段连接成功后统一创建
+func DeclareIndexes(mod idef.ModName, collName string, models ...mongo.IndexModel) {
+	indexesMu.Lock()
+	defer indexesMu.Unlock()
+	if indexes[mod] == nil {
+		indexes[mod] = map[string][]mongo.IndexModel{}
+	}
+	indexes[mod][collName] = append(indexes[mod][collName], models...)
+}
+
+func (m *module) createIndexes() error {
+	indexesMu.Lock()
+	defer indexesMu.Unlock()
+	for collName, models := range indexes[m.Name()] {
+		ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
+		names, err := m.database.Collection(collName).Indexes().CreateMany(ctx, models)
+		cancel()
+		if err != nil {
+			return err
+		}
+		zlog.Infof("mongo create indexes %s %v", collName, names)
+	}
+	return nil
+}
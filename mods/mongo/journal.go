@@ -0,0 +1,261 @@
+package mongo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/tnnmigga/core/infra/zlog"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 预写日志
+// 重试后仍失败的保存操作追加写入本地文件, 下次启动连接数据库后重放
+// 每条记录为一个bson文档, 进程崩溃导致的不完整尾部记录在读取时丢弃
+// 内存中按文档保留最后一次失败的值, 之后按_id写入该文档成功时追加删除记录
+// 保证重放不会覆盖更新的数据, 按条件批量写入(UpdateMany等)无法确定文档, 不影响日志
+// 失效的记录超过有效记录且不少于compactMin时重写文件
+type journal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	pending map[string]*journalOp // 未写入数据库的文档 按journalKey索引
+	dead    int                   // 文件中已失效的记录数
+}
+
+const compactMin = 1024
+
+type journalRecord struct {
+	CollName string         `bson:"coll"`
+	Ops      []*MongoSaveOp `bson:"ops,omitempty"`
+	Done     []string       `bson:"done,omitempty"` // 已写入数据库的文档 journalKey
+}
+
+type journalOp struct {
+	collName string
+	op       *MongoSaveOp
+}
+
+func newJournal(path string) *journal {
+	return &journal{
+		path:    path,
+		pending: map[string]*journalOp{},
+	}
+}
+
+// 追加失败的保存操作
+func (j *journal) append(collName string, ops []*MongoSaveOp) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.write(&journalRecord{CollName: collName, Ops: ops}); err != nil {
+		return err
+	}
+	j.apply(collName, ops, nil)
+	return nil
+}
+
+// 按记录更新内存中的文档 被覆盖或删除的记录计为失效
+// 调用方持有锁
+func (j *journal) apply(collName string, ops []*MongoSaveOp, done []string) {
+	for _, op := range ops {
+		key := journalKey(collName, op.Filter)
+		if _, ok := j.pending[key]; ok {
+			j.dead++
+		}
+		j.pending[key] = &journalOp{collName: collName, op: op}
+	}
+	for _, key := range done {
+		delete(j.pending, key)
+		j.dead += 2 // 原记录和删除记录
+	}
+}
+
+// 写入成功后调用 移除日志中被这些filter写入的文档
+// 只移除按_id(或完全相同的filter)匹配到的记录, 无法确定写入了哪些文档时保留
+func (j *journal) written(collName string, filters []bson.M) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.pending) == 0 {
+		return
+	}
+	var done []string
+	for _, filter := range filters {
+		key := journalKey(collName, filter)
+		if _, ok := j.pending[key]; ok {
+			done = append(done, key)
+		}
+	}
+	if len(done) == 0 {
+		return
+	}
+	if err := j.write(&journalRecord{CollName: collName, Done: done}); err != nil {
+		// 删除记录未落盘时不能只改内存, 否则重放会覆盖更新的数据
+		zlog.Errorf("mongo journal %s done error %v", collName, err)
+		return
+	}
+	j.apply(collName, nil, done)
+	zlog.Infof("mongo journal %s %d ops superseded", collName, len(done))
+	if len(j.pending) == 0 || (j.dead >= compactMin && j.dead > len(j.pending)) {
+		if err := j.compact(); err != nil {
+			zlog.Errorf("mongo journal compact error %v", err)
+		}
+	}
+}
+
+// 按集合分组返回未写入的操作
+func (j *journal) records() []*journalRecord {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	byColl := map[string]*journalRecord{}
+	var records []*journalRecord
+	for _, p := range j.pending {
+		r, ok := byColl[p.collName]
+		if !ok {
+			r = &journalRecord{CollName: p.collName}
+			byColl[p.collName] = r
+			records = append(records, r)
+		}
+		r.Ops = append(r.Ops, p.op)
+	}
+	return records
+}
+
+func (j *journal) write(records ...*journalRecord) error {
+	if j.file == nil {
+		if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		j.file = file
+	}
+	var buf []byte
+	for _, record := range records {
+		b, err := bson.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, b...)
+	}
+	if _, err := j.file.Write(buf); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// 读取上次运行遗留的记录 同一文档以最后一条为准
+func (j *journal) load() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	file, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	header := make([]byte, 4)
+	for n := 0; ; n++ {
+		if _, err := io.ReadFull(file, header); err != nil {
+			if err != io.EOF {
+				zlog.Errorf("mongo journal %s tail broken, %d records read", j.path, n)
+			}
+			return nil
+		}
+		size := binary.LittleEndian.Uint32(header)
+		if size < 5 {
+			return fmt.Errorf("mongo journal %s broken after %d records", j.path, n)
+		}
+		b := make([]byte, size)
+		copy(b, header)
+		if _, err := io.ReadFull(file, b[4:]); err != nil {
+			zlog.Errorf("mongo journal %s tail broken, %d records read", j.path, n)
+			return nil
+		}
+		record := &journalRecord{}
+		if err := bson.Unmarshal(b, record); err != nil {
+			return fmt.Errorf("mongo journal %s record %d broken: %v", j.path, n, err)
+		}
+		j.apply(record.CollName, record.Ops, record.Done)
+	}
+}
+
+// 用内存中未写入的操作重写日志 一次写入并同步, 全部写入后删除文件
+// 调用方持有锁
+func (j *journal) compact() error {
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+	if len(j.pending) == 0 {
+		j.dead = 0
+		err := os.Remove(j.path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	records := make([]*journalRecord, 0, len(j.pending))
+	for _, p := range j.pending {
+		records = append(records, &journalRecord{CollName: p.collName, Ops: []*MongoSaveOp{p.op}})
+	}
+	old := j.path
+	j.path = tmp
+	err := j.write(records...)
+	j.path = old
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, old); err != nil {
+		return err
+	}
+	j.dead = 0
+	return nil
+}
+
+func (j *journal) close() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+}
+
+// 文档的唯一表示 filter含_id时按集合和_id, 否则按完整的filter
+// fmt输出map时按key排序
+func journalKey(collName string, filter bson.M) string {
+	if id, ok := filterID(filter); ok {
+		return fmt.Sprintf("%s/%s", collName, idString(id))
+	}
+	return fmt.Sprintf("%s-%v", collName, filter)
+}
+
+// filter按_id精确匹配单个文档时返回_id
+func filterID(filter bson.M) (any, bool) {
+	id, ok := filter["_id"]
+	if !ok {
+		return nil, false
+	}
+	switch id.(type) {
+	case bson.M, bson.D, map[string]any:
+		return nil, false // 操作符
+	}
+	return id, true
+}
@@ -0,0 +1,130 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/migrate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const migrationColl = "schema_migrations"
+
+var (
+	migrations   = map[idef.ModName][]migrate.Migration[*mongo.Database]{}
+	migrationsMu sync.Mutex
+)
+
+// 声明迁移
+// 需在服务器初始化前声明, mongo模块在初始化阶段连接成功后在集群锁内执行
+// 已应用的版本记录在schema_migrations集合
+func DeclareMigrations(mod idef.ModName, ms ...migrate.Migration[*mongo.Database]) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	migrations[mod] = append(migrations[mod], ms...)
+}
+
+// 从.json文件生成迁移 文件格式见migrate.ReadFiles
+// 文件内容为数据库命令的数组(扩展JSON), 依次通过runCommand执行
+// 如[{"createIndexes": "user", "indexes": [{"key": {"name": 1}, "name": "name_1"}]}]
+// mongo 4.2起不再支持服务端执行JS, 不支持.js迁移, 复杂的数据迁移在Go中注册
+func CommandMigrations(fsys fs.FS, dir string) ([]migrate.Migration[*mongo.Database], error) {
+	files, err := migrate.ReadFiles(fsys, dir, ".json")
+	if err != nil {
+		return nil, err
+	}
+	ms := make([]migrate.Migration[*mongo.Database], 0, len(files))
+	for _, f := range files {
+		m := migrate.Migration[*mongo.Database]{
+			Version: f.Version,
+			Name:    f.Name,
+		}
+		if m.Up, err = runCommandFile(f.Up); err != nil {
+			return nil, fmt.Errorf("migrate file %d_%s up: %w", f.Version, f.Name, err)
+		}
+		if f.Down != nil {
+			if m.Down, err = runCommandFile(f.Down); err != nil {
+				return nil, fmt.Errorf("migrate file %d_%s down: %w", f.Version, f.Name, err)
+			}
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+func runCommandFile(b []byte) (func(context.Context, *mongo.Database) error, error) {
+	var doc struct {
+		Commands []bson.D `bson:"commands"`
+	}
+	wrapped := append(append([]byte(`{"commands":`), b...), '}')
+	if err := bson.UnmarshalExtJSON(wrapped, false, &doc); err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, cmd := range doc.Commands {
+			if err := db.RunCommand(ctx, cmd).Err(); err != nil {
+				return wrapError(err)
+			}
+		}
+		return nil
+	}, nil
+}
+
+func (m *module) migrate() error {
+	migrationsMu.Lock()
+	ms := migrations[m.Name()]
+	migrationsMu.Unlock()
+	if len(ms) == 0 {
+		return nil
+	}
+	store := migrateStore{m.database.Collection(migrationColl)}
+	_, err := migrate.Run("mongo/"+m.dbName, m.database, store, ms, migrate.ConfOptions(string(m.Name())))
+	return err
+}
+
+type migrateStore struct {
+	coll *mongo.Collection
+}
+
+type migrationRecord struct {
+	Version   uint64    `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+func (s migrateStore) Ensure(ctx context.Context) error {
+	return nil // 集合在首次写入时创建
+}
+
+func (s migrateStore) Applied(ctx context.Context) ([]uint64, error) {
+	cur, err := s.coll.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	var records []migrationRecord
+	if err := cur.All(ctx, &records); err != nil {
+		return nil, wrapError(err)
+	}
+	versions := make([]uint64, 0, len(records))
+	for _, r := range records {
+		versions = append(versions, r.Version)
+	}
+	return versions, nil
+}
+
+func (s migrateStore) Mark(ctx context.Context, version uint64, name string) error {
+	_, err := s.coll.InsertOne(ctx, &migrationRecord{Version: version, Name: name, AppliedAt: time.Now()})
+	return wrapError(err)
+}
+
+func (s migrateStore) Unmark(ctx context.Context, version uint64) error {
+	_, err := s.coll.DeleteOne(ctx, bson.M{"_id": version})
+	return wrapError(err)
+}
@@ -2,9 +2,14 @@ package mongo
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	"github.com/tnnmigga/core/conc"
+	"github.com/tnnmigga/core/conf"
 	"github.com/tnnmigga/core/idef"
 	"github.com/tnnmigga/core/mods/basic"
 
@@ -20,8 +25,13 @@ type module struct {
 	semaphore conc.Semaphore // 控制并发数
 	mongoCli  *mongo.Client  // mongo
 	database  *mongo.Database
+	connected atomic.Bool // 已连接 之后mongoCli和database不再修改
 	mongoURI  string
 	dbName    string
+	maxResult int64 // 单次查询最多返回的文档数
+	retries   int   // 保存失败的重试次数
+	backoff   time.Duration
+	journal   *journal
 }
 
 func New(name idef.ModName, uri string, dbName string) idef.IModule {
@@ -30,14 +40,20 @@ func New(name idef.ModName, uri string, dbName string) idef.IModule {
 		semaphore: conc.NewSemaphore(MaxConcurrency),
 		mongoURI:  uri,
 		dbName:    dbName,
+		maxResult: conf.Int64("mongo.max-results", 10000),
+		retries:   conf.Int("mongo.save-retries", 3),
+		backoff:   time.Duration(conf.Int("mongo.save-backoff", 200)) * time.Millisecond,
+		journal:   newJournal(filepath.Join(conf.String("mongo.journal-dir", "data"), fmt.Sprintf("mongo-%s-%d.journal", name, conf.ServerID))),
 	}
 	m.registerHandler()
-	m.Before(idef.ServerStateRun, m.beforeRun)
+	m.After(idef.ServerStateInit, m.afterInit)
 	m.After(idef.ServerStateStop, m.afterStop)
 	return m
 }
 
-func (m *module) beforeRun() (err error) {
+// 初始化阶段连接数据库, 创建声明的索引, 执行迁移并重放预写日志
+// 依赖mongo的模块在之后的运行阶段即可读写
+func (m *module) afterInit() (err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 	m.mongoCli, err = mongo.Connect(ctx, options.Client().ApplyURI(m.mongoURI))
@@ -48,10 +64,27 @@ func (m *module) beforeRun() (err error) {
 		return err
 	}
 	m.database = m.mongoCli.Database(m.dbName)
-	return nil
+	m.connected.Store(true)
+	if err := m.createIndexes(); err != nil {
+		return err
+	}
+	if err := m.migrate(); err != nil {
+		return err
+	}
+	return m.replayJournal()
+}
+
+func (m *module) HealthCheck() error {
+	if !m.connected.Load() {
+		return errors.New("mongo not connected")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return m.mongoCli.Ping(ctx, readpref.Primary())
 }
 
 func (m *module) afterStop() (err error) {
 	m.mongoCli.Disconnect(context.Background())
+	m.journal.close()
 	return nil
 }
@@ -0,0 +1,65 @@
+package mongo
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 分页游标 记录上一页最后一个文档的排序字段值和_id
+type pageCursor struct {
+	Value bson.RawValue `bson:"v"`
+	ID    bson.RawValue `bson:"id"`
+}
+
+func pageToken(last bson.Raw, key string) (string, error) {
+	c := pageCursor{}
+	var err error
+	if c.ID, err = last.LookupErr("_id"); err != nil {
+		return "", fmt.Errorf("%w: document without _id", ErrInvalidRequest)
+	}
+	if c.Value, err = last.LookupErr(key); err != nil {
+		return "", fmt.Errorf("%w: sort key %s not projected", ErrInvalidRequest, key)
+	}
+	b, err := bson.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// 在原条件上追加游标条件
+func pageFilter(filter bson.M, key string, desc bool, token string) (bson.M, error) {
+	if token == "" {
+		if filter == nil {
+			return bson.M{}, nil
+		}
+		return filter, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	c := pageCursor{}
+	if err := bson.Unmarshal(b, &c); err != nil {
+		return nil, ErrInvalidToken
+	}
+	op := "$gt"
+	if desc {
+		op = "$lt"
+	}
+	var cond bson.M
+	if key == "_id" {
+		cond = bson.M{"_id": bson.M{op: c.ID}}
+	} else {
+		cond = bson.M{"$or": bson.A{
+			bson.M{key: bson.M{op: c.Value}},
+			bson.M{key: c.Value, "_id": bson.M{op: c.ID}},
+		}}
+	}
+	if len(filter) == 0 {
+		return cond, nil
+	}
+	return bson.M{"$and": bson.A{filter, cond}}, nil
+}
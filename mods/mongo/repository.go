@@ -0,0 +1,315 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/msgbus"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrNoID = errors.New("mongo document has no _id")
+
+// 类型化的集合访问
+// 通过msgbus RPC访问mongo模块, 文档的序列化在调用方完成, 回调由调用方模块协程执行
+// 同一文档(按_id)的写操作保证时序
+type Repository[T any] struct {
+	caller   idef.IModule
+	collName string
+	target   msgbus.CastOpt
+}
+
+type RepoOption func(*repoOptions)
+
+type repoOptions struct {
+	target   msgbus.CastOpt
+	indexMod idef.ModName
+	indexes  []mongo.IndexModel
+}
+
+// 指定mongo模块所在进程 默认为本进程
+func WithTarget(target msgbus.CastOpt) RepoOption {
+	return func(o *repoOptions) {
+		o.target = target
+	}
+}
+
+// 声明集合索引 由名为mod的mongo模块在初始化阶段创建
+func WithIndexes(mod idef.ModName, models ...mongo.IndexModel) RepoOption {
+	return func(o *repoOptions) {
+		o.indexMod = mod
+		o.indexes = append(o.indexes, models...)
+	}
+}
+
+func NewRepository[T any](caller idef.IModule, collName string, opts ...RepoOption) *Repository[T] {
+	o := &repoOptions{
+		target: msgbus.Local(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if len(o.indexes) > 0 {
+		DeclareIndexes(o.indexMod, collName, o.indexes...)
+	}
+	return &Repository[T]{
+		caller:   caller,
+		collName: collName,
+		target:   o.target,
+	}
+}
+
+// 加载单个文档 不存在时返回ErrNoDocuments
+func (r *Repository[T]) Load(filter bson.M, cb func(*T, error)) {
+	req := &MongoLoadSingle{
+		GroupKey: r.groupKey(filter),
+		CollName: r.collName,
+		Filter:   filter,
+	}
+	msgbus.RPC(r.caller, r.target, req, func(raw bson.Raw, err error) {
+		if err != nil {
+			cb(nil, err)
+			return
+		}
+		doc := new(T)
+		if err := bson.Unmarshal(raw, doc); err != nil {
+			cb(nil, err)
+			return
+		}
+		cb(doc, nil)
+	})
+}
+
+// 加载所有匹配的文档
+func (r *Repository[T]) LoadMany(filter bson.M, cb func([]*T, error)) {
+	req := &MongoLoadMulti{
+		GroupKey: r.groupKey(filter),
+		CollName: r.collName,
+		Filter:   filter,
+	}
+	msgbus.RPC(r.caller, r.target, req, func(raws []bson.Raw, err error) {
+		if err != nil {
+			cb(nil, err)
+			return
+		}
+		cb(unmarshalMany[T](raws))
+	})
+}
+
+// 分页加载 token为空时从第一页开始, 回调的next为空表示没有更多数据
+// sortKey为空时按_id排序
+func (r *Repository[T]) LoadPage(filter bson.M, sortKey string, desc bool, pageSize int64, token string, cb func(docs []*T, next string, err error)) {
+	req := &MongoLoadPage{
+		GroupKey: r.groupKey(filter),
+		CollName: r.collName,
+		Filter:   filter,
+		SortKey:  sortKey,
+		Desc:     desc,
+		PageSize: pageSize,
+		Token:    token,
+	}
+	msgbus.RPC(r.caller, r.target, req, func(page *MongoPage, err error) {
+		if err != nil {
+			cb(nil, "", err)
+			return
+		}
+		docs, err := unmarshalMany[T](page.Docs)
+		if err != nil {
+			cb(nil, "", err)
+			return
+		}
+		cb(docs, page.Next, nil)
+	})
+}
+
+// 按_id保存文档 不存在则插入
+// cb可以为nil, 此时错误只记录日志
+func (r *Repository[T]) Save(doc *T, cb func(error)) {
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		r.done(cb, err)
+		return
+	}
+	id, err := bson.Raw(b).LookupErr("_id")
+	if err != nil {
+		r.done(cb, ErrNoID)
+		return
+	}
+	r.replace(bson.M{"_id": id}, b, cb)
+}
+
+// 替换filter匹配的文档 不存在则插入
+// cb可以为nil, 此时错误只记录日志
+func (r *Repository[T]) Upsert(filter bson.M, doc *T, cb func(error)) {
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		r.done(cb, err)
+		return
+	}
+	r.replace(filter, b, cb)
+}
+
+// 删除filter匹配的单个文档 回调参数为删除的数量
+func (r *Repository[T]) Delete(filter bson.M, cb func(int64, error)) {
+	req := &MongoDeleteOne{
+		GroupKey: r.groupKey(filter),
+		CollName: r.collName,
+		Filter:   filter,
+	}
+	msgbus.RPC(r.caller, r.target, req, func(res *MongoResult, err error) {
+		r.deleted(res, err, cb)
+	})
+}
+
+// 删除filter匹配的所有文档 回调参数为删除的数量
+func (r *Repository[T]) DeleteMany(filter bson.M, cb func(int64, error)) {
+	req := &MongoDeleteMany{
+		GroupKey: r.groupKey(filter),
+		CollName: r.collName,
+		Filter:   filter,
+	}
+	msgbus.RPC(r.caller, r.target, req, func(res *MongoResult, err error) {
+		r.deleted(res, err, cb)
+	})
+}
+
+// 按更新操作符更新filter匹配的单个文档
+func (r *Repository[T]) Update(filter, update bson.M, upsert bool, cb func(*MongoResult, error)) {
+	req := &MongoUpdateOne{
+		GroupKey: r.groupKey(filter),
+		CollName: r.collName,
+		Filter:   filter,
+		Update:   update,
+		Upsert:   upsert,
+	}
+	msgbus.RPC(r.caller, r.target, req, func(res *MongoResult, err error) {
+		if cb != nil {
+			cb(res, err)
+		} else if err != nil {
+			zlog.Errorf("mongo repository %s update error %v", r.collName, err)
+		}
+	})
+}
+
+// 原子地更新并返回更新后的文档
+func (r *Repository[T]) FindOneAndUpdate(filter, update bson.M, upsert bool, cb func(*T, error)) {
+	req := &MongoFindOneAndUpdate{
+		GroupKey:  r.groupKey(filter),
+		CollName:  r.collName,
+		Filter:    filter,
+		Update:    update,
+		Upsert:    upsert,
+		ReturnNew: true,
+	}
+	msgbus.RPC(r.caller, r.target, req, func(raw bson.Raw, err error) {
+		if err != nil {
+			cb(nil, err)
+			return
+		}
+		doc := new(T)
+		if err := bson.Unmarshal(raw, doc); err != nil {
+			cb(nil, err)
+			return
+		}
+		cb(doc, nil)
+	})
+}
+
+// 统计filter匹配的文档数量
+func (r *Repository[T]) Count(filter bson.M, cb func(int64, error)) {
+	req := &MongoCount{
+		GroupKey: r.groupKey(filter),
+		CollName: r.collName,
+		Filter:   filter,
+	}
+	msgbus.RPC(r.caller, r.target, req, func(res *MongoCountResult, err error) {
+		if err != nil {
+			cb(0, err)
+			return
+		}
+		cb(res.Count, nil)
+	})
+}
+
+func (r *Repository[T]) replace(filter bson.M, b []byte, cb func(error)) {
+	req := &MongoReplaceOne{
+		GroupKey: r.groupKey(filter),
+		CollName: r.collName,
+		Filter:   filter,
+		Value:    b,
+		Upsert:   true,
+	}
+	msgbus.RPC(r.caller, r.target, req, func(_ *MongoResult, err error) {
+		r.done(cb, err)
+	})
+}
+
+func (r *Repository[T]) deleted(res *MongoResult, err error, cb func(int64, error)) {
+	var n int64
+	if res != nil {
+		n = res.DeletedCount
+	}
+	if cb != nil {
+		cb(n, err)
+	} else if err != nil {
+		zlog.Errorf("mongo repository %s delete error %v", r.collName, err)
+	}
+}
+
+func (r *Repository[T]) done(cb func(error), err error) {
+	if cb != nil {
+		cb(err)
+	} else if err != nil {
+		zlog.Errorf("mongo repository %s write error %v", r.collName, err)
+	}
+}
+
+// 按_id分组保证同一文档的时序 无_id时按集合分组
+func (r *Repository[T]) groupKey(filter bson.M) string {
+	id, ok := filter["_id"]
+	if !ok {
+		return r.collName
+	}
+	return fmt.Sprintf("%s-%s", r.collName, idString(id))
+}
+
+// 同一个_id无论是Go值还是bson.RawValue, 无论整数位宽, 都得到相同的字符串
+func idString(id any) string {
+	v, ok := id.(bson.RawValue)
+	if !ok {
+		t, b, err := bson.MarshalValue(id)
+		if err != nil {
+			return fmt.Sprint(id)
+		}
+		v = bson.RawValue{Type: t, Value: b}
+	}
+	switch v.Type {
+	case bsontype.String:
+		return v.StringValue()
+	case bsontype.ObjectID:
+		return v.ObjectID().Hex()
+	case bsontype.Int32:
+		return strconv.FormatInt(int64(v.Int32()), 10)
+	case bsontype.Int64:
+		return strconv.FormatInt(v.Int64(), 10)
+	}
+	return v.String()
+}
+
+func unmarshalMany[T any](raws []bson.Raw) ([]*T, error) {
+	docs := make([]*T, 0, len(raws))
+	for _, raw := range raws {
+		doc := new(T)
+		if err := bson.Unmarshal(raw, doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
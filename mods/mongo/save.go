@@ -0,0 +1,103 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/utils/clock"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 保存失败时依次重试 写入预写日志 通知调用方
+func (m *module) persist(collName string, ops []*MongoSaveOp, notify idef.ModName) {
+	_, err := m.saveWithRetry(collName, ops)
+	if err == nil {
+		m.journal.written(collName, opFilters(ops))
+		return
+	}
+	zlog.Errorf("mongo save %s error %v", collName, err)
+	if err := m.journal.append(collName, ops); err != nil {
+		zlog.Errorf("mongo journal append %s error %v, %d ops lost", collName, err, len(ops))
+	}
+	m.notifyWriteFailed(notify, collName, ops, err)
+}
+
+// 暂时性错误按退避重试
+func (m *module) saveWithRetry(collName string, ops []*MongoSaveOp) (*MongoResult, error) {
+	backoff := m.backoff
+	for i := 0; ; i++ {
+		res, err := m.save(collName, ops)
+		if err == nil || i >= m.retries || !isTransient(err) {
+			return res, err
+		}
+		zlog.Warnf("mongo save %s error %v, retry %d after %v", collName, err, i+1, backoff)
+		clock.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (m *module) save(collName string, ops []*MongoSaveOp) (*MongoResult, error) {
+	ms := make([]mongo.WriteModel, 0, len(ops))
+	for _, op := range ops {
+		ms = append(ms, mongo.NewReplaceOneModel().SetFilter(op.Filter).SetReplacement(op.Value).SetUpsert(true))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mongoOpTimeout)
+	defer cancel()
+	res, err := m.database.Collection(collName).BulkWrite(ctx, ms)
+	if err != nil {
+		return nil, err
+	}
+	return &MongoResult{
+		MatchedCount:  res.MatchedCount,
+		ModifiedCount: res.ModifiedCount,
+		UpsertedCount: res.UpsertedCount,
+	}, nil
+}
+
+func isTransient(err error) bool {
+	err = wrapError(err)
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrNetwork) || errors.Is(err, ErrWriteConflict)
+}
+
+// 重放上次运行遗留的预写日志 失败的记录保留到下次启动
+func (m *module) replayJournal() error {
+	if err := m.journal.load(); err != nil {
+		return err
+	}
+	records := m.journal.records()
+	if len(records) == 0 {
+		return nil
+	}
+	failed := 0
+	for _, record := range records {
+		if _, err := m.saveWithRetry(record.CollName, record.Ops); err != nil {
+			zlog.Errorf("mongo journal replay %s error %v", record.CollName, err)
+			failed += len(record.Ops)
+			continue
+		}
+		m.journal.written(record.CollName, opFilters(record.Ops))
+	}
+	zlog.Infof("mongo journal replayed %d collections, %d ops remain", len(records), failed)
+	return nil
+}
+
+// 写入成功后通知预写日志 日志中的同一文档不再重放
+// 未匹配到文档的写入不影响日志
+func (m *module) written(collName string, filter bson.M, res *MongoResult) {
+	if res.MatchedCount+res.UpsertedCount+res.DeletedCount == 0 {
+		return
+	}
+	m.journal.written(collName, []bson.M{filter})
+}
+
+func opFilters(ops []*MongoSaveOp) []bson.M {
+	filters := make([]bson.M, 0, len(ops))
+	for _, op := range ops {
+		filters = append(filters, op.Filter)
+	}
+	return filters
+}
@@ -1,6 +1,19 @@
 package mysql
 
-import "gorm.io/gorm"
+import (
+	"errors"
+
+	"github.com/tnnmigga/core/msgbus"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidRequest = errors.New("mysql invalid request")
+
+func init() {
+	msgbus.RegisterError("mysql.invalid-request", ErrInvalidRequest)
+}
 
 type (
 	Raw  map[string]any
@@ -21,15 +34,18 @@ type ExecSQL struct {
 }
 
 // 直接根据sql执行
+// 配置了从库时在从库执行, Primary为true时读主库
 // 支持跨进程调用
 // GroupKey为保证并发时的时序
 type RawSQL struct {
 	GroupKey string
 	SQL      string
 	Args     []any
+	Primary  bool
 }
 
 // 简单查询
+// 配置了从库时在从库执行, Primary为true时读主库
 // 支持跨进程调用
 // GroupKey为保证并发时的时序
 type First struct {
@@ -38,9 +54,67 @@ type First struct {
 	Select   []string
 	Where    string
 	Args     []any
+	Primary  bool
+}
+
+// 查询 结果按行编码, 通过ScanRows或Query转为结构体切片
+// 配置了从库时在从库执行, Primary为true时读主库
+// 支持跨进程调用
+// GroupKey为保证并发时的时序
+// 返回*Rows
+type QuerySQL struct {
+	GroupKey string
+	SQL      string
+	Args     []any
+	Primary  bool
+}
+
+// 查询结果 每行为一个bson文档, key为列名
+type Rows struct {
+	Columns []string
+	Data    []bson.Raw
+}
+
+// 事务中的一条语句
+type TxStmt struct {
+	SQL  string
+	Args []any
+}
+
+// 在一个事务中依次执行多条语句 任一失败全部回滚
+// 注意MySQL的DDL会隐式提交, 不应放在事务中
+// 支持跨进程调用
+// GroupKey为保证并发时的时序
+// 返回*TxResult
+type TxSQL struct {
+	GroupKey string
+	Stmts    []*TxStmt
+}
+
+type TxResult struct {
+	RowsAffected []int64 // 每条语句影响的行数
+}
+
+// 批量插入 Rows中每行的值与Columns一一对应
+// Update不为空时为upsert, 主键或唯一键冲突时以插入的值更新Update中的列
+// 按BatchSize分批在一个事务中执行 默认500
+// 支持跨进程调用
+// GroupKey为保证并发时的时序
+// 返回*ExecResult
+type BatchInsert struct {
+	GroupKey  string
+	Table     string
+	Columns   []string
+	Rows      [][]any
+	Update    []string
+	BatchSize int
+}
+
+type ExecResult struct {
+	RowsAffected int64
 }
 
-// 使用gorm执行
+// 使用gorm执行 传入的连接为主库
 // 不支持跨进程调用
 // 传入一个执行函数进程gorm操作
 // 返回需要的结果为RPC回调函数需要的参数
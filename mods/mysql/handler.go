@@ -1,8 +1,13 @@
 package mysql
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/tnnmigga/core/conc"
 	"github.com/tnnmigga/core/msgbus"
+	"github.com/tnnmigga/core/utils"
+	"gorm.io/gorm"
 )
 
 func (m *module) initHandler() {
@@ -10,13 +15,18 @@ func (m *module) initHandler() {
 	msgbus.RegisterRPC(m, m.onRawSQL)
 	msgbus.RegisterRPC(m, m.onExecGORM)
 	msgbus.RegisterRPC(m, m.onFirst)
+	msgbus.RegisterRPC(m, m.onQuerySQL)
+	msgbus.RegisterRPC(m, m.onTxSQL)
+	msgbus.RegisterRPC(m, m.onBatchInsert)
 }
 
 func (m *module) onExecSQL(req *ExecSQL, resolve func(any), reject func(error)) {
 	conc.GoWithGroup(req.GroupKey, func() {
 		m.semaphore.P()
 		defer m.semaphore.V()
-		err := m.gormDB.Exec(req.SQL, req.Args...).Error
+		db, cancel := m.primary()
+		defer cancel()
+		err := db.Exec(req.SQL, sqlArgs(req.Args)...).Error
 		if err != nil {
 			reject(err)
 			return
@@ -29,8 +39,10 @@ func (m *module) onRawSQL(req *RawSQL, resolve func(any), reject func(error)) {
 	conc.GoWithGroup(req.GroupKey, func() {
 		m.semaphore.P()
 		defer m.semaphore.V()
+		db, cancel := m.reader(req.Primary)
+		defer cancel()
 		var raws []map[string]any
-		err := m.gormDB.Raw(req.SQL, req.Args...).Scan(&raws).Error
+		err := db.Raw(req.SQL, sqlArgs(req.Args)...).Scan(&raws).Error
 		if err != nil {
 			reject(err)
 			return
@@ -43,8 +55,10 @@ func (m *module) onFirst(req *First, resolve func(any), reject func(error)) {
 	conc.GoWithGroup(req.GroupKey, func() {
 		m.semaphore.P()
 		defer m.semaphore.V()
+		db, cancel := m.reader(req.Primary)
+		defer cancel()
 		var res map[string]any
-		err := m.gormDB.Table(req.Table).Where(req.Where, req.Args...).Select(req.Select).Limit(1).Scan(&res).Error
+		err := db.Table(req.Table).Where(req.Where, sqlArgs(req.Args)...).Select(req.Select).Limit(1).Scan(&res).Error
 		if err != nil {
 			reject(err)
 			return
@@ -57,7 +71,91 @@ func (m *module) onExecGORM(req *ExecGORM, resolve func(any), reject func(error)
 	conc.GoWithGroup(req.GroupKey, func() {
 		m.semaphore.P()
 		defer m.semaphore.V()
-		res, err := req.GORM(m.gormDB)
+		db, cancel := m.primary()
+		defer cancel()
+		res, err := req.GORM(db)
+		if err != nil {
+			reject(err)
+			return
+		}
+		resolve(res)
+	})
+}
+
+func (m *module) onQuerySQL(req *QuerySQL, resolve func(any), reject func(error)) {
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		db, cancel := m.reader(req.Primary)
+		defer cancel()
+		rows, err := db.Raw(req.SQL, sqlArgs(req.Args)...).Rows()
+		if err != nil {
+			reject(err)
+			return
+		}
+		defer rows.Close()
+		res, err := encodeRows(rows)
+		if err != nil {
+			reject(err)
+			return
+		}
+		resolve(res)
+	})
+}
+
+func (m *module) onTxSQL(req *TxSQL, resolve func(any), reject func(error)) {
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		db, cancel := m.primary()
+		defer cancel()
+		res := &TxResult{RowsAffected: make([]int64, 0, len(req.Stmts))}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for i, stmt := range req.Stmts {
+				r := tx.Exec(stmt.SQL, sqlArgs(stmt.Args)...)
+				if r.Error != nil {
+					return fmt.Errorf("stmt %d: %w", i, r.Error)
+				}
+				res.RowsAffected = append(res.RowsAffected, r.RowsAffected)
+			}
+			return nil
+		})
+		if err != nil {
+			reject(err)
+			return
+		}
+		resolve(res)
+	})
+}
+
+func (m *module) onBatchInsert(req *BatchInsert, resolve func(any), reject func(error)) {
+	head, err := batchInsertSQL(req)
+	if err != nil {
+		reject(err)
+		return
+	}
+	size := req.BatchSize
+	if size <= 0 {
+		size = 500
+	}
+	conc.GoWithGroup(req.GroupKey, func() {
+		m.semaphore.P()
+		defer m.semaphore.V()
+		db, cancel := m.primary()
+		defer cancel()
+		res := &ExecResult{}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for start := 0; start < len(req.Rows); start += size {
+				rows := req.Rows[start:utils.Min(start+size, len(req.Rows))]
+				sql, args := head(rows)
+				r := tx.Exec(sql, args...)
+				if r.Error != nil {
+					return r.Error
+				}
+				res.RowsAffected += r.RowsAffected
+			}
+			return nil
+		})
 		if err != nil {
 			reject(err)
 			return
@@ -65,3 +163,45 @@ func (m *module) onExecGORM(req *ExecGORM, resolve func(any), reject func(error)
 		resolve(res)
 	})
 }
+
+// 校验请求并返回按行生成语句的函数
+func batchInsertSQL(req *BatchInsert) (func(rows [][]any) (string, []any), error) {
+	if len(req.Columns) == 0 || len(req.Rows) == 0 {
+		return nil, fmt.Errorf("%w: batch insert %s without columns or rows", ErrInvalidRequest, req.Table)
+	}
+	names := append([]string{req.Table}, req.Columns...)
+	for _, name := range append(names, req.Update...) {
+		if name == "" || strings.ContainsRune(name, '`') {
+			return nil, fmt.Errorf("%w: invalid identifier %q", ErrInvalidRequest, name)
+		}
+	}
+	for i, row := range req.Rows {
+		if len(row) != len(req.Columns) {
+			return nil, fmt.Errorf("%w: batch insert %s row %d has %d values, expect %d", ErrInvalidRequest, req.Table, i, len(row), len(req.Columns))
+		}
+	}
+	insert := fmt.Sprintf("INSERT INTO `%s` (`%s`) VALUES ", req.Table, strings.Join(req.Columns, "`, `"))
+	placeholder := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(req.Columns)), ", ") + ")"
+	var upsert string
+	if len(req.Update) > 0 {
+		sets := make([]string, 0, len(req.Update))
+		for _, col := range req.Update {
+			sets = append(sets, fmt.Sprintf("`%s` = VALUES(`%s`)", col, col))
+		}
+		upsert = " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	}
+	return func(rows [][]any) (string, []any) {
+		var b strings.Builder
+		b.WriteString(insert)
+		args := make([]any, 0, len(rows)*len(req.Columns))
+		for i, row := range rows {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(placeholder)
+			args = append(args, sqlArgs(row)...)
+		}
+		b.WriteString(upsert)
+		return b.String(), args
+	}, nil
+}
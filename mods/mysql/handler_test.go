@@ -0,0 +1,110 @@
+package mysql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tnnmigga/core/utils"
+)
+
+func TestBatchInsertSQL(t *testing.T) {
+	cases := []struct {
+		name string
+		req  *BatchInsert
+		sql  []string // 每批的语句
+		args [][]any
+		err  bool
+	}{
+		{
+			name: "insert",
+			req: &BatchInsert{
+				Table:   "user",
+				Columns: []string{"id", "name"},
+				Rows:    [][]any{{1, "a"}, {2, "b"}},
+			},
+			sql:  []string{"INSERT INTO `user` (`id`, `name`) VALUES (?, ?), (?, ?)"},
+			args: [][]any{{1, "a", 2, "b"}},
+		},
+		{
+			name: "upsert",
+			req: &BatchInsert{
+				Table:   "user",
+				Columns: []string{"id", "name", "level"},
+				Rows:    [][]any{{1, "a", 3}},
+				Update:  []string{"name", "level"},
+			},
+			sql:  []string{"INSERT INTO `user` (`id`, `name`, `level`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `level` = VALUES(`level`)"},
+			args: [][]any{{1, "a", 3}},
+		},
+		{
+			name: "batches",
+			req: &BatchInsert{
+				Table:     "t",
+				Columns:   []string{"v"},
+				Rows:      [][]any{{1}, {2}, {3}},
+				BatchSize: 2,
+			},
+			sql:  []string{"INSERT INTO `t` (`v`) VALUES (?), (?)", "INSERT INTO `t` (`v`) VALUES (?)"},
+			args: [][]any{{1, 2}, {3}},
+		},
+		{
+			name: "no rows",
+			req:  &BatchInsert{Table: "t", Columns: []string{"v"}},
+			err:  true,
+		},
+		{
+			name: "no columns",
+			req:  &BatchInsert{Table: "t", Rows: [][]any{{1}}},
+			err:  true,
+		},
+		{
+			name: "row length mismatch",
+			req:  &BatchInsert{Table: "t", Columns: []string{"a", "b"}, Rows: [][]any{{1, 2}, {3}}},
+			err:  true,
+		},
+		{
+			name: "backtick in table",
+			req:  &BatchInsert{Table: "t`; DROP TABLE x; --", Columns: []string{"v"}, Rows: [][]any{{1}}},
+			err:  true,
+		},
+		{
+			name: "backtick in update column",
+			req:  &BatchInsert{Table: "t", Columns: []string{"v"}, Rows: [][]any{{1}}, Update: []string{"v`"}},
+			err:  true,
+		},
+		{
+			name: "empty column",
+			req:  &BatchInsert{Table: "t", Columns: []string{""}, Rows: [][]any{{1}}},
+			err:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			head, err := batchInsertSQL(c.req)
+			if c.err {
+				if !errors.Is(err, ErrInvalidRequest) {
+					t.Fatalf("err %v, want %v", err, ErrInvalidRequest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			size := c.req.BatchSize
+			if size <= 0 {
+				size = len(c.req.Rows)
+			}
+			for i := range c.sql {
+				end := utils.Min((i+1)*size, len(c.req.Rows))
+				sql, args := head(c.req.Rows[i*size : end])
+				if sql != c.sql[i] {
+					t.Fatalf("batch %d sql\n%s\nwant\n%s", i, sql, c.sql[i])
+				}
+				if !reflect.DeepEqual(args, c.args[i]) {
+					t.Fatalf("batch %d args %v, want %v", i, args, c.args[i])
+				}
+			}
+		})
+	}
+}
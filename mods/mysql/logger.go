@@ -2,14 +2,18 @@ package mysql
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/tnnmigga/core/infra/zlog"
 	"go.uber.org/zap/zapcore"
+	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-type gormLogger struct{}
+type gormLogger struct {
+	slow time.Duration // 慢查询阈值 0表示不记录
+}
 
 func (l gormLogger) LogMode(logger.LogLevel) logger.Interface {
 	return l
@@ -37,8 +41,13 @@ func (l gormLogger) Error(ctx context.Context, f string, s ...interface{}) {
 }
 
 func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
-	if err != nil {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, ra := fc()
+		zlog.Errorf("exec sql error %v, elapsed: %v, SQL: %s, rows affected: %d", err, elapsed, sql, ra)
+	case l.slow > 0 && elapsed > l.slow:
 		sql, ra := fc()
-		zlog.Errorf("exec sql error %v, SQL: %s, rows affected: %d", err, sql, ra)
+		zlog.Warnf("slow sql elapsed: %v, threshold: %v, SQL: %s, rows affected: %d", elapsed, l.slow, sql, ra)
 	}
 }
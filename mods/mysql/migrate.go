@@ -0,0 +1,122 @@
+package mysql
+
+import (
+	"context"
+	"io/fs"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/migrate"
+	"gorm.io/gorm"
+)
+
+const migrationTable = "schema_migrations"
+
+var (
+	migrations   = map[idef.ModName][]migrate.Migration[*gorm.DB]{}
+	migrationsMu sync.Mutex
+)
+
+// 声明迁移
+// 需在服务器初始化前声明, mysql模块在初始化阶段连接成功后在集群锁内执行
+// 已应用的版本记录在schema_migrations表
+func DeclareMigrations(mod idef.ModName, ms ...migrate.Migration[*gorm.DB]) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	migrations[mod] = append(migrations[mod], ms...)
+}
+
+var sqlSeparator = regexp.MustCompile(`;\s*(\n|$)`)
+
+// 从.sql文件生成迁移 文件格式见migrate.ReadFiles
+// 多条语句以行尾的分号分隔, 整行的--注释会被忽略
+// MySQL的DDL会隐式提交, 一个文件中途失败时之前的语句不会回滚, 应尽量一个文件一个DDL
+func SQLMigrations(fsys fs.FS, dir string) ([]migrate.Migration[*gorm.DB], error) {
+	files, err := migrate.ReadFiles(fsys, dir, ".sql")
+	if err != nil {
+		return nil, err
+	}
+	ms := make([]migrate.Migration[*gorm.DB], 0, len(files))
+	for _, f := range files {
+		m := migrate.Migration[*gorm.DB]{
+			Version: f.Version,
+			Name:    f.Name,
+			Up:      execSQLFile(f.Up),
+		}
+		if f.Down != nil {
+			m.Down = execSQLFile(f.Down)
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+func execSQLFile(b []byte) func(context.Context, *gorm.DB) error {
+	var lines []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	var stmts []string
+	for _, stmt := range sqlSeparator.Split(strings.Join(lines, "\n"), -1) {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return func(ctx context.Context, db *gorm.DB) error {
+		for _, stmt := range stmts {
+			if err := db.WithContext(ctx).Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (m *module) migrate() error {
+	migrationsMu.Lock()
+	ms := migrations[m.Name()]
+	migrationsMu.Unlock()
+	if len(ms) == 0 {
+		return nil
+	}
+	name := "mysql/" + m.gormDB.Migrator().CurrentDatabase()
+	_, err := migrate.Run(name, m.gormDB, migrateStore{m.gormDB}, ms, migrate.ConfOptions(string(m.Name())))
+	return err
+}
+
+type migrateStore struct {
+	db *gorm.DB
+}
+
+type migrationRecord struct {
+	Version   uint64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	AppliedAt time.Time
+}
+
+func (s migrateStore) table(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Table(migrationTable)
+}
+
+func (s migrateStore) Ensure(ctx context.Context) error {
+	return s.table(ctx).AutoMigrate(&migrationRecord{})
+}
+
+func (s migrateStore) Applied(ctx context.Context) ([]uint64, error) {
+	var versions []uint64
+	err := s.table(ctx).Pluck("version", &versions).Error
+	return versions, err
+}
+
+func (s migrateStore) Mark(ctx context.Context, version uint64, name string) error {
+	return s.table(ctx).Create(&migrationRecord{Version: version, Name: name, AppliedAt: time.Now()}).Error
+}
+
+func (s migrateStore) Unmark(ctx context.Context, version uint64) error {
+	return s.table(ctx).Where("version = ?", version).Delete(&migrationRecord{}).Error
+}
@@ -1,7 +1,14 @@
 package mysql
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
 	"github.com/tnnmigga/core/conc"
+	"github.com/tnnmigga/core/conf"
 	"github.com/tnnmigga/core/idef"
 	"github.com/tnnmigga/core/mods/basic"
 	"gorm.io/driver/mysql"
@@ -15,6 +22,19 @@ type module struct {
 	semaphore conc.Semaphore // 控制并发数
 	gormDB    *gorm.DB
 	mysqlDSN  string
+	replicas  []*gorm.DB  // 只读从库 为空时读写都走主库
+	connected atomic.Bool // 主库和从库均已连接 之后gormDB和replicas不再修改
+	next      atomic.Uint32
+	timeout   time.Duration // 单次操作超时
+	pool      poolConfig
+}
+
+// 连接池配置
+type poolConfig struct {
+	maxOpen     int
+	maxIdle     int
+	maxLifetime time.Duration
+	maxIdleTime time.Duration
 }
 
 func New(name idef.ModName, dsn string) idef.IModule {
@@ -22,20 +42,101 @@ func New(name idef.ModName, dsn string) idef.IModule {
 		Module:    basic.New(name, basic.DefaultMQLen),
 		semaphore: conc.NewSemaphore(MaxConcurrency),
 		mysqlDSN:  dsn,
+		timeout:   time.Duration(conf.Int("mysql.timeout", 10000)) * time.Millisecond,
+		pool: poolConfig{
+			maxOpen:     conf.Int("mysql.max-open", 64),
+			maxIdle:     conf.Int("mysql.max-idle", 16),
+			maxLifetime: time.Duration(conf.Int("mysql.conn-max-lifetime", 3600)) * time.Second,
+			maxIdleTime: time.Duration(conf.Int("mysql.conn-max-idle-time", 600)) * time.Second,
+		},
 	}
 	m.initHandler()
-	m.Before(idef.ServerStateRun, m.beforeRun)
+	m.After(idef.ServerStateInit, m.afterInit)
+	m.After(idef.ServerStateStop, m.afterStop)
 	return m
 }
 
-func (m *module) beforeRun() error {
-	mdb := mysql.Open(m.mysqlDSN)
-	db, err := gorm.Open(mdb, &gorm.Config{
-		Logger: gormLogger{},
-	})
+func (m *module) HealthCheck() error {
+	if !m.connected.Load() {
+		return errors.New("mysql not connected")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, gdb := range append([]*gorm.DB{m.gormDB}, m.replicas...) {
+		db, err := gdb.DB()
+		if err != nil {
+			return err
+		}
+		if err := db.PingContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 初始化阶段连接主库和从库并执行声明的迁移
+func (m *module) afterInit() error {
+	db, err := m.open(m.mysqlDSN)
 	if err != nil {
 		return err
 	}
 	m.gormDB = db
+	for i, dsn := range conf.Array[string]("mysql.replicas", nil) {
+		replica, err := m.open(dsn)
+		if err != nil {
+			return fmt.Errorf("mysql replica %d error %w", i, err)
+		}
+		m.replicas = append(m.replicas, replica)
+	}
+	m.connected.Store(true)
+	return m.migrate()
+}
+
+func (m *module) open(dsn string) (*gorm.DB, error) {
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger: gormLogger{
+			slow: time.Duration(conf.Int("mysql.slow-threshold", 200)) * time.Millisecond,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(m.pool.maxOpen)
+	sqlDB.SetMaxIdleConns(m.pool.maxIdle)
+	sqlDB.SetConnMaxLifetime(m.pool.maxLifetime)
+	sqlDB.SetConnMaxIdleTime(m.pool.maxIdleTime)
+	return db, nil
+}
+
+func (m *module) afterStop() error {
+	for _, gdb := range append([]*gorm.DB{m.gormDB}, m.replicas...) {
+		if gdb == nil {
+			continue
+		}
+		if db, err := gdb.DB(); err == nil {
+			db.Close()
+		}
+	}
 	return nil
 }
+
+// 写操作使用的主库连接 带操作超时
+func (m *module) primary() (*gorm.DB, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	return m.gormDB.WithContext(ctx), cancel
+}
+
+// 读操作使用的连接 从库轮询, 没有从库或指定读主库时使用主库
+// 从库存在复制延迟, 需要读到刚写入的数据时应读主库
+func (m *module) reader(primary bool) (*gorm.DB, context.CancelFunc) {
+	if primary || len(m.replicas) == 0 {
+		return m.primary()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	db := m.replicas[int(m.next.Add(1))%len(m.replicas)]
+	return db.WithContext(ctx), cancel
+}
@@ -0,0 +1,137 @@
+package mysql
+
+import (
+	"database/sql"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/msgbus"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 查询并转为结构体切片 支持跨进程调用
+// T的字段通过bson标签对应列名, 如`bson:"user_id"`
+// 回调由caller模块协程执行
+func Query[T any](caller idef.IModule, target msgbus.CastOpt, req *QuerySQL, cb func([]*T, error)) {
+	msgbus.RPC(caller, target, req, func(rows *Rows, err error) {
+		if err != nil {
+			cb(nil, err)
+			return
+		}
+		cb(ScanRows[T](rows))
+	})
+}
+
+// 将查询结果转为结构体切片
+// 整数列为int64, 浮点列为float64, 二进制列为[]byte, 其余(包括DECIMAL)为字符串
+// DSN未设置parseTime时时间列同样为字符串
+func ScanRows[T any](rows *Rows) ([]*T, error) {
+	res := make([]*T, 0, len(rows.Data))
+	for _, raw := range rows.Data {
+		v := new(T)
+		if err := bson.Unmarshal(raw, v); err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func encodeRows(rows *sql.Rows) (*Rows, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	res := &Rows{Columns: make([]string, len(types))}
+	for i, t := range types {
+		res.Columns[i] = t.Name()
+	}
+	values := make([]any, len(types))
+	dest := make([]any, len(types))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		doc := make(bson.D, len(types))
+		for i, t := range types {
+			doc[i] = bson.E{Key: res.Columns[i], Value: columnValue(t.DatabaseTypeName(), values[i])}
+		}
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		res.Data = append(res.Data, raw)
+	}
+	return res, rows.Err()
+}
+
+// 文本协议返回的[]byte按列类型转换
+func columnValue(typeName string, v any) any {
+	switch v := v.(type) {
+	case []byte:
+		switch {
+		case strings.HasSuffix(typeName, "INT") || typeName == "YEAR":
+			if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+				return n
+			}
+			return string(v) // 超出int64的无符号整数
+		case typeName == "FLOAT" || typeName == "DOUBLE":
+			if f, err := strconv.ParseFloat(string(v), 64); err == nil {
+				return f
+			}
+		case strings.Contains(typeName, "BLOB") || strings.Contains(typeName, "BINARY") || typeName == "BIT" || typeName == "GEOMETRY":
+			return v
+		}
+		return string(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return strconv.FormatUint(v, 10)
+		}
+		return int64(v)
+	}
+	return v
+}
+
+// 跨进程传输后参数被bson解码为primitive类型, 转回驱动可绑定的类型
+// 本地调用时参数不变, 返回新的切片不修改请求
+func sqlArgs(args []any) []any {
+	if len(args) == 0 {
+		return args
+	}
+	res := make([]any, len(args))
+	for i, arg := range args {
+		res[i] = sqlArg(arg)
+	}
+	return res
+}
+
+func sqlArg(v any) any {
+	switch v := v.(type) {
+	case primitive.DateTime:
+		return v.Time()
+	case primitive.Binary:
+		return v.Data
+	case primitive.Timestamp:
+		return time.Unix(int64(v.T), 0)
+	case primitive.Decimal128:
+		return v.String()
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.Symbol:
+		return string(v)
+	case primitive.Null, primitive.Undefined:
+		return nil
+	case primitive.A:
+		// IN (?) 的参数
+		return sqlArgs(v)
+	}
+	return v
+}
@@ -0,0 +1,92 @@
+package mysql
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestColumnValue(t *testing.T) {
+	cases := []struct {
+		typeName string
+		v        any
+		want     any
+	}{
+		{"INT", []byte("42"), int64(42)},
+		{"BIGINT", []byte("-7"), int64(-7)},
+		{"UNSIGNED BIGINT", []byte("18446744073709551615"), "18446744073709551615"},
+		{"TINYINT", []byte("1"), int64(1)},
+		{"YEAR", []byte("2024"), int64(2024)},
+		{"DOUBLE", []byte("1.5"), 1.5},
+		{"FLOAT", []byte("bad"), "bad"},
+		{"DECIMAL", []byte("10.25"), "10.25"},
+		{"VARCHAR", []byte("abc"), "abc"},
+		{"DATETIME", []byte("2024-01-02 03:04:05"), "2024-01-02 03:04:05"},
+		{"BLOB", []byte{0, 1, 2}, []byte{0, 1, 2}},
+		{"VARBINARY", []byte{3}, []byte{3}},
+		{"BIT", []byte{1}, []byte{1}},
+		{"BIGINT", uint64(5), int64(5)},
+		{"BIGINT", uint64(math.MaxUint64), "18446744073709551615"},
+		{"BIGINT", int64(3), int64(3)},
+		{"VARCHAR", nil, nil},
+	}
+	for _, c := range cases {
+		if got := columnValue(c.typeName, c.v); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("columnValue(%s, %v) = %#v, want %#v", c.typeName, c.v, got, c.want)
+		}
+	}
+}
+
+// 参数经过bson编解码(跨进程RPC)后恢复为驱动可绑定的类型
+func TestSQLArgsRemote(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	args := []any{int64(1), "a", now, []byte{1, 2}, 1.5, true, nil, []any{int64(1), int64(2)}}
+	b, err := bson.Marshal(&ExecSQL{Args: args})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &ExecSQL{}
+	if err := bson.Unmarshal(b, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := req.Args[2].(primitive.DateTime); !ok {
+		t.Fatalf("decoded time is %T", req.Args[2])
+	}
+	got := sqlArgs(req.Args)
+	if !got[2].(time.Time).Equal(now) {
+		t.Fatalf("time %v, want %v", got[2], now)
+	}
+	got[2] = now
+	if !reflect.DeepEqual(got, args) {
+		t.Fatalf("args %#v, want %#v", got, args)
+	}
+}
+
+func TestSQLArg(t *testing.T) {
+	oid := primitive.NewObjectID()
+	dec, _ := primitive.ParseDecimal128("12.34")
+	cases := []struct {
+		v    any
+		want any
+	}{
+		{primitive.Binary{Data: []byte("x")}, []byte("x")},
+		{primitive.Timestamp{T: 100}, time.Unix(100, 0)},
+		{dec, "12.34"},
+		{oid, oid.Hex()},
+		{primitive.Symbol("s"), "s"},
+		{primitive.Null{}, nil},
+		{primitive.Undefined{}, nil},
+		{primitive.A{primitive.Binary{Data: []byte("y")}, int32(1)}, []any{[]byte("y"), int32(1)}},
+		{int32(7), int32(7)},
+		{"plain", "plain"},
+	}
+	for _, c := range cases {
+		if got := sqlArg(c.v); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("sqlArg(%#v) = %#v, want %#v", c.v, got, c.want)
+		}
+	}
+}
@@ -19,3 +19,86 @@ type ExecMulti struct {
 	Key     string        // 默认为Cmds[0][1]
 	Timeout time.Duration // 默认为3s
 }
+
+// 以下为类型化的命令 支持跨进程调用, 可以通过helper.go中的函数调用
+// 同一Key的命令保证时序
+// 消息ID由类型名生成, 类型名带Redis前缀避免与其他模块的同名消息冲突
+
+// 返回*RedisStringResult, key不存在时Nil为true
+type RedisGet struct {
+	Key string
+}
+
+// TTL为0表示不过期
+// 返回*RedisStringResult
+type RedisSet struct {
+	Key   string
+	Value any
+	TTL   time.Duration
+}
+
+// 返回*RedisIntResult 为删除的数量
+// cluster模式下所有key需在同一个slot, 否则返回CROSSSLOT错误, 可以使用{hash tag}
+type RedisDel struct {
+	Keys []string
+}
+
+// 返回*RedisIntResult 为增加后的值
+type RedisIncrBy struct {
+	Key   string
+	Value int64
+}
+
+// 返回*RedisIntResult key存在时为1
+type RedisExpire struct {
+	Key string
+	TTL time.Duration
+}
+
+// 返回*RedisHashResult
+type RedisHGetAll struct {
+	Key string
+}
+
+// 返回*RedisIntResult 为新增的字段数
+type RedisHSet struct {
+	Key    string
+	Fields map[string]any
+}
+
+// 返回*RedisIntResult 为新增的成员数
+type RedisZAdd struct {
+	Key     string
+	Members []Z
+}
+
+// 按排名范围查询有序集合 Rev为true时按分数从高到低
+// 返回*RedisZResult
+type RedisZRangeWithScores struct {
+	Key   string
+	Start int64
+	Stop  int64
+	Rev   bool
+}
+
+type Z struct {
+	Member string
+	Score  float64
+}
+
+type RedisStringResult struct {
+	Value string
+	Nil   bool
+}
+
+type RedisIntResult struct {
+	Value int64
+}
+
+type RedisHashResult struct {
+	Fields map[string]string
+}
+
+type RedisZResult struct {
+	Members []Z
+}
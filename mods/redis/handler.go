@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/tnnmigga/core/conc"
@@ -17,6 +18,15 @@ var ErrInvalidCmd = errors.New("invalid command")
 func (m *module) initHandler() {
 	msgbus.RegisterRPC(m, m.onExec)
 	msgbus.RegisterRPC(m, m.onExecMulti)
+	msgbus.RegisterRPC(m, m.onGet)
+	msgbus.RegisterRPC(m, m.onSet)
+	msgbus.RegisterRPC(m, m.onDel)
+	msgbus.RegisterRPC(m, m.onIncrBy)
+	msgbus.RegisterRPC(m, m.onExpire)
+	msgbus.RegisterRPC(m, m.onHGetAll)
+	msgbus.RegisterRPC(m, m.onHSet)
+	msgbus.RegisterRPC(m, m.onZAdd)
+	msgbus.RegisterRPC(m, m.onZRangeWithScores)
 }
 
 func (m *module) onExec(req *Exec, resolve func(any), reject func(error)) {
@@ -67,3 +77,109 @@ func (m *module) onExecMulti(req *ExecMulti, resolve func(any), reject func(erro
 		resolve(results)
 	})
 }
+
+// 在key所属的协程组中执行类型化的命令
+func (m *module) do(key string, f func(ctx context.Context) (any, error), resolve func(any), reject func(error)) {
+	conc.GoWithGroup(key, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		res, err := f(ctx)
+		if err != nil {
+			reject(err)
+			return
+		}
+		resolve(res)
+	})
+}
+
+func (m *module) onGet(req *RedisGet, resolve func(any), reject func(error)) {
+	m.do(req.Key, func(ctx context.Context) (any, error) {
+		v, err := m.cli.Get(ctx, req.Key).Result()
+		if err == redis.Nil {
+			return &RedisStringResult{Nil: true}, nil
+		}
+		return &RedisStringResult{Value: v}, err
+	}, resolve, reject)
+}
+
+func (m *module) onSet(req *RedisSet, resolve func(any), reject func(error)) {
+	m.do(req.Key, func(ctx context.Context) (any, error) {
+		v, err := m.cli.Set(ctx, req.Key, req.Value, req.TTL).Result()
+		return &RedisStringResult{Value: v}, err
+	}, resolve, reject)
+}
+
+func (m *module) onDel(req *RedisDel, resolve func(any), reject func(error)) {
+	if len(req.Keys) == 0 {
+		reject(ErrInvalidCmd)
+		return
+	}
+	m.do(req.Keys[0], func(ctx context.Context) (any, error) {
+		n, err := m.cli.Del(ctx, req.Keys...).Result()
+		return &RedisIntResult{Value: n}, err
+	}, resolve, reject)
+}
+
+func (m *module) onIncrBy(req *RedisIncrBy, resolve func(any), reject func(error)) {
+	m.do(req.Key, func(ctx context.Context) (any, error) {
+		n, err := m.cli.IncrBy(ctx, req.Key, req.Value).Result()
+		return &RedisIntResult{Value: n}, err
+	}, resolve, reject)
+}
+
+func (m *module) onExpire(req *RedisExpire, resolve func(any), reject func(error)) {
+	m.do(req.Key, func(ctx context.Context) (any, error) {
+		ok, err := m.cli.Expire(ctx, req.Key, req.TTL).Result()
+		return &RedisIntResult{Value: utils.IfElse[int64](ok, 1, 0)}, err
+	}, resolve, reject)
+}
+
+func (m *module) onHGetAll(req *RedisHGetAll, resolve func(any), reject func(error)) {
+	m.do(req.Key, func(ctx context.Context) (any, error) {
+		fields, err := m.cli.HGetAll(ctx, req.Key).Result()
+		return &RedisHashResult{Fields: fields}, err
+	}, resolve, reject)
+}
+
+func (m *module) onHSet(req *RedisHSet, resolve func(any), reject func(error)) {
+	if len(req.Fields) == 0 {
+		reject(ErrInvalidCmd)
+		return
+	}
+	m.do(req.Key, func(ctx context.Context) (any, error) {
+		n, err := m.cli.HSet(ctx, req.Key, req.Fields).Result()
+		return &RedisIntResult{Value: n}, err
+	}, resolve, reject)
+}
+
+func (m *module) onZAdd(req *RedisZAdd, resolve func(any), reject func(error)) {
+	if len(req.Members) == 0 {
+		reject(ErrInvalidCmd)
+		return
+	}
+	members := make([]*redis.Z, 0, len(req.Members))
+	for _, z := range req.Members {
+		members = append(members, &redis.Z{Member: z.Member, Score: z.Score})
+	}
+	m.do(req.Key, func(ctx context.Context) (any, error) {
+		n, err := m.cli.ZAdd(ctx, req.Key, members...).Result()
+		return &RedisIntResult{Value: n}, err
+	}, resolve, reject)
+}
+
+func (m *module) onZRangeWithScores(req *RedisZRangeWithScores, resolve func(any), reject func(error)) {
+	m.do(req.Key, func(ctx context.Context) (any, error) {
+		var zs []redis.Z
+		var err error
+		if req.Rev {
+			zs, err = m.cli.ZRevRangeWithScores(ctx, req.Key, req.Start, req.Stop).Result()
+		} else {
+			zs, err = m.cli.ZRangeWithScores(ctx, req.Key, req.Start, req.Stop).Result()
+		}
+		res := &RedisZResult{Members: make([]Z, 0, len(zs))}
+		for _, z := range zs {
+			res.Members = append(res.Members, Z{Member: fmt.Sprint(z.Member), Score: z.Score})
+		}
+		return res, err
+	}, resolve, reject)
+}
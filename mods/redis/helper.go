@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/msgbus"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 类型化的命令调用 回调均由caller模块协程执行
+// target为redis模块所在进程, 本进程使用msgbus.Local()
+
+// 读取字符串 key不存在时ok为false
+func GetString(caller idef.IModule, target msgbus.CastOpt, key string, cb func(v string, ok bool, err error)) {
+	msgbus.RPC(caller, target, &RedisGet{Key: key}, func(res *RedisStringResult, err error) {
+		if err != nil {
+			cb("", false, err)
+			return
+		}
+		cb(res.Value, !res.Nil, nil)
+	})
+}
+
+// 写入 ttl为0表示不过期 cb可以为nil
+func SetValue(caller idef.IModule, target msgbus.CastOpt, key string, v any, ttl time.Duration, cb func(error)) {
+	msgbus.RPC(caller, target, &RedisSet{Key: key, Value: v, TTL: ttl}, func(_ *RedisStringResult, err error) {
+		done(cb, err)
+	})
+}
+
+// 删除 回调参数为删除的数量
+// cluster模式下所有key需在同一个slot
+func DelKeys(caller idef.IModule, target msgbus.CastOpt, keys []string, cb func(int64, error)) {
+	msgbus.RPC(caller, target, &RedisDel{Keys: keys}, intCb(cb))
+}
+
+// 原子增加 回调参数为增加后的值
+func Incr(caller idef.IModule, target msgbus.CastOpt, key string, n int64, cb func(int64, error)) {
+	msgbus.RPC(caller, target, &RedisIncrBy{Key: key, Value: n}, intCb(cb))
+}
+
+// 设置过期时间 回调参数为key是否存在
+func ExpireKey(caller idef.IModule, target msgbus.CastOpt, key string, ttl time.Duration, cb func(bool, error)) {
+	msgbus.RPC(caller, target, &RedisExpire{Key: key, TTL: ttl}, func(res *RedisIntResult, err error) {
+		if cb != nil {
+			cb(err == nil && res.Value == 1, err)
+		}
+	})
+}
+
+// 读取哈希表到结构体 T的字段通过redis标签对应哈希字段, 如`redis:"level"`
+// key不存在时回调nil, nil
+func HGetAllInto[T any](caller idef.IModule, target msgbus.CastOpt, key string, cb func(*T, error)) {
+	msgbus.RPC(caller, target, &RedisHGetAll{Key: key}, func(res *RedisHashResult, err error) {
+		if err != nil || len(res.Fields) == 0 {
+			cb(nil, err)
+			return
+		}
+		v := new(T)
+		if err := redis.NewStringStringMapResult(res.Fields, nil).Scan(v); err != nil {
+			cb(nil, err)
+			return
+		}
+		cb(v, nil)
+	})
+}
+
+// 写入哈希表字段 回调参数为新增的字段数
+func HSetFields(caller idef.IModule, target msgbus.CastOpt, key string, fields map[string]any, cb func(int64, error)) {
+	msgbus.RPC(caller, target, &RedisHSet{Key: key, Fields: fields}, intCb(cb))
+}
+
+// 添加有序集合成员 回调参数为新增的成员数
+func ZAddMembers(caller idef.IModule, target msgbus.CastOpt, key string, members []Z, cb func(int64, error)) {
+	msgbus.RPC(caller, target, &RedisZAdd{Key: key, Members: members}, intCb(cb))
+}
+
+// 按排名范围查询有序集合 rev为true时按分数从高到低
+func ZRange(caller idef.IModule, target msgbus.CastOpt, key string, start, stop int64, rev bool, cb func([]Z, error)) {
+	req := &RedisZRangeWithScores{Key: key, Start: start, Stop: stop, Rev: rev}
+	msgbus.RPC(caller, target, req, func(res *RedisZResult, err error) {
+		if err != nil {
+			cb(nil, err)
+			return
+		}
+		cb(res.Members, nil)
+	})
+}
+
+func intCb(cb func(int64, error)) func(*RedisIntResult, error) {
+	return func(res *RedisIntResult, err error) {
+		if cb == nil {
+			return
+		}
+		if err != nil {
+			cb(0, err)
+			return
+		}
+		cb(res.Value, nil)
+	}
+}
+
+func done(cb func(error), err error) {
+	if cb != nil {
+		cb(err)
+	} else if err != nil {
+		zlog.Errorf("redis command error %v", err)
+	}
+}
@@ -4,25 +4,33 @@ import (
 	"context"
 	"time"
 
+	"github.com/tnnmigga/core/conf"
 	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/zlog"
 	"github.com/tnnmigga/core/mods/basic"
+	"github.com/tnnmigga/core/msgbus"
 
 	"github.com/go-redis/redis/v8"
 )
 
+func init() {
+	// key不存在 跨进程调用时同样可以通过errors.Is判断
+	msgbus.RegisterError("redis.nil", redis.Nil)
+}
+
 type module struct {
 	*basic.Module
-	cli *redis.Client
+	cli redis.UniversalClient
 }
 
+// 部署模式由redis.mode配置 single(默认)/cluster/sentinel
+// cluster和sentinel模式的节点地址由redis.addrs配置, 未配置时使用addr
+// sentinel模式需要配置redis.master-name
+// cluster模式下ExecMulti的所有key需在同一个slot, 可以使用{hash tag}
 func New(name idef.ModName, addr, username, password string) idef.IModule {
 	m := &module{
 		Module: basic.New(name, basic.DefaultMQLen),
-		cli: redis.NewClient(&redis.Options{
-			Addr:     addr,
-			Username: username,
-			Password: password,
-		}),
+		cli:    newClient(addr, username, password),
 	}
 	m.After(idef.ServerStateInit, m.afterInit)
 	m.After(idef.ServerStateStop, m.afterStop)
@@ -40,6 +48,42 @@ func (m *module) afterInit() error {
 	return nil
 }
 
+// cli在New中创建之后不再修改, 可以在模块协程外直接读取
+func (m *module) HealthCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return m.cli.Ping(ctx).Err()
+}
+
 func (m *module) afterStop() error {
 	return m.cli.Close()
 }
+
+func newClient(addr, username, password string) redis.UniversalClient {
+	opts := &redis.UniversalOptions{
+		Addrs:            conf.Array[string]("redis.addrs", []string{addr}),
+		Username:         username,
+		Password:         password,
+		MasterName:       conf.String("redis.master-name", ""),
+		SentinelPassword: conf.String("redis.sentinel-password", ""),
+		PoolSize:         conf.Int("redis.pool-size", 0), // 0为go-redis默认值
+	}
+	if len(opts.Addrs) == 0 {
+		opts.Addrs = []string{addr}
+	}
+	switch mode := conf.String("redis.mode", "single"); mode {
+	case "single":
+		opts.Addrs = []string{addr}
+		return redis.NewClient(opts.Simple())
+	case "cluster":
+		return redis.NewClusterClient(opts.Cluster())
+	case "sentinel":
+		if opts.MasterName == "" {
+			zlog.Panicf("redis sentinel mode without master-name")
+		}
+		return redis.NewFailoverClient(opts.Failover())
+	default:
+		zlog.Panicf("redis mode %s not support", mode)
+	}
+	return nil
+}
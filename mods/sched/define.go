@@ -0,0 +1,66 @@
+package sched
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tnnmigga/core/codec"
+	"github.com/tnnmigga/core/infra/cluster"
+)
+
+// 调度集群任务
+// 到期后将消息投递给一个ServerType类型进程的本地模块, 目标进程也需要运行sched模块
+// HashKey不为空时按哈希选择目标进程, 节点不变时同一HashKey总投递到同一进程
+// 调度进程退出不影响任务执行, 由其他进程的sched模块接管
+// 返回*JobResp
+type ScheduleJob struct {
+	At         int64 // 到期时间 纳秒
+	ServerType string
+	HashKey    string
+	Data       []byte // codec编码后的消息
+}
+
+// 取消集群任务
+// 任务已执行或不存在时返回cluster.ErrJobNotFound
+// 返回*JobResp
+type CancelJob struct {
+	ID string
+}
+
+// 投递到期任务 由目标进程的sched模块处理
+// 返回*JobResp
+type JobDeliver struct {
+	ID   string
+	Key  string // 去重键 同一任务的重复投递相同
+	Data []byte
+}
+
+// 需要去重的任务消息实现该接口
+// 投递前设置去重键, 同一任务的重复投递键相同, 不同任务的键不同
+type IJobMsg interface {
+	SetJobKey(key string)
+}
+
+// 任务的去重键 由任务ID和首次到期时间组成
+func JobKey(job *cluster.Job) string {
+	return fmt.Sprintf("%s-%d", job.ID, job.FireAt)
+}
+
+type JobResp struct {
+	ID string
+}
+
+// 构造任务 msg需要是已注册的消息类型
+func NewScheduleJob(at time.Time, serverType string, msg any) *ScheduleJob {
+	return &ScheduleJob{
+		At:         at.UnixNano(),
+		ServerType: serverType,
+		Data:       codec.Encode(msg),
+	}
+}
+
+// 到期且已认领的任务 由轮询协程投递给模块协程
+type jobDue struct {
+	job      *cluster.Job
+	serverID uint32 // 按哈希选出的目标进程 0表示随机
+}
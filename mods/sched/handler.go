@@ -0,0 +1,85 @@
+package sched
+
+import (
+	"errors"
+
+	"github.com/tnnmigga/core/codec"
+	"github.com/tnnmigga/core/infra/cluster"
+	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/msgbus"
+	"github.com/tnnmigga/core/utils"
+)
+
+func (m *module) initHandler() {
+	msgbus.RegisterHandler(m, m.onJobDue)
+	msgbus.RegisterRPC(m, m.onScheduleJob)
+	msgbus.RegisterRPC(m, m.onCancelJob)
+	msgbus.RegisterRPC(m, m.onJobDeliver)
+}
+
+func (m *module) onScheduleJob(req *ScheduleJob, resolve func(any), reject func(error)) {
+	if req.ServerType == "" || len(req.Data) == 0 {
+		reject(errors.New("sched job server type or data empty"))
+		return
+	}
+	job := &cluster.Job{
+		At:         req.At,
+		ServerType: req.ServerType,
+		HashKey:    req.HashKey,
+		Data:       req.Data,
+	}
+	m.Async(func() (any, error) {
+		err := cluster.PutJob(job)
+		return job.ID, err
+	}, func(id any, err error) {
+		if err != nil {
+			reject(err)
+			return
+		}
+		resolve(&JobResp{ID: id.(string)})
+	})
+}
+
+func (m *module) onCancelJob(req *CancelJob, resolve func(any), reject func(error)) {
+	m.Async(func() (any, error) {
+		return nil, cluster.DeleteJob(req.ID)
+	}, func(_ any, err error) {
+		if err != nil {
+			reject(err)
+			return
+		}
+		resolve(&JobResp{ID: req.ID})
+	})
+}
+
+// 目标进程收到任务后投递给本地模块
+func (m *module) onJobDeliver(req *JobDeliver, resolve func(any), reject func(error)) {
+	msg, err := codec.Decode(req.Data)
+	if err != nil {
+		reject(err)
+		return
+	}
+	if jm, ok := msg.(IJobMsg); ok {
+		jm.SetJobKey(req.Key)
+	}
+	msgbus.Cast(msg)
+	resolve(&JobResp{ID: req.ID})
+}
+
+func (m *module) onJobDue(due *jobDue) {
+	job := due.job
+	target := utils.IfElse(due.serverID != 0, msgbus.ServerID(due.serverID), msgbus.ServerType(job.ServerType))
+	msgbus.RPC(m, target, &JobDeliver{ID: job.ID, Key: JobKey(job), Data: job.Data}, func(resp *JobResp, err error) {
+		m.Async(func() (any, error) {
+			if err != nil {
+				m.retry(job, err)
+				return nil, nil
+			}
+			return nil, cluster.DeleteJob(job.ID)
+		}, func(_ any, err error) {
+			if err != nil {
+				zlog.Errorf("sched delete job %s error %v", job.ID, err)
+			}
+		})
+	})
+}
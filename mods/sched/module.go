@@ -0,0 +1,125 @@
+package sched
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tnnmigga/core/conc"
+	"github.com/tnnmigga/core/conf"
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/cluster"
+	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/mods/basic"
+	"github.com/tnnmigga/core/utils"
+	"github.com/tnnmigga/core/utils/clock"
+	"github.com/tnnmigga/core/utils/idgen"
+)
+
+// 集群任务调度模块
+// 任务存储在etcd中, 各进程的sched模块轮询到期任务, 认领后投递
+// 认领绑定节点租约, 投递成功后删除任务, 失败则退避重试, 超过claim-ttl未完成时重新认领
+// 投递保证为至少一次: 目标已处理但响应丢失, 或删除前进程退出时任务会被再次投递
+// 消息处理需要幂等, 实现IJobMsg的消息可按JobKey去重
+type module struct {
+	*basic.Module
+	interval    time.Duration // 轮询间隔
+	batch       int           // 每次轮询最多处理的任务数
+	maxAttempts int           // 最大投递次数 超过后丢弃
+	backoff     time.Duration // 首次重试间隔 之后翻倍
+	maxBackoff  time.Duration
+	claimTTL    time.Duration // 认领后未完成投递的任务在此之后重新认领 需大于RPC超时
+}
+
+func New(name idef.ModName) idef.IModule {
+	m := &module{
+		Module:      basic.New(name, basic.DefaultMQLen),
+		interval:    time.Duration(conf.Int("sched.interval", 1000)) * time.Millisecond,
+		batch:       conf.Int("sched.batch", 100),
+		maxAttempts: conf.Int("sched.max-attempts", 10),
+		backoff:     time.Duration(conf.Int("sched.backoff", 1000)) * time.Millisecond,
+		maxBackoff:  time.Duration(conf.Int("sched.max-backoff", 60000)) * time.Millisecond,
+		claimTTL:    time.Duration(conf.Int("sched.claim-ttl", 60000)) * time.Millisecond,
+	}
+	m.initHandler()
+	m.After(idef.ServerStateRun, m.afterRun)
+	return m
+}
+
+func (m *module) afterRun() error {
+	conc.Go(m.poll)
+	return nil
+}
+
+// 轮询到期任务 etcd操作均在轮询协程中执行
+func (m *module) poll(ctx context.Context) {
+	ticker := clock.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			m.claimDueJobs()
+		}
+	}
+}
+
+func (m *module) claimDueJobs() {
+	ids, err := cluster.DueJobs(clock.Now(), m.batch)
+	if err != nil {
+		zlog.LimitErrorf("sched due jobs error %v", err)
+		return
+	}
+	for _, id := range ids {
+		job, err := cluster.ClaimJob(id, clock.Now().Add(m.claimTTL))
+		if errors.Is(err, cluster.ErrJobClaimed) || errors.Is(err, cluster.ErrJobNotFound) {
+			continue
+		}
+		if err != nil {
+			zlog.LimitErrorf("sched claim job %s error %v", id, err)
+			continue
+		}
+		due := &jobDue{job: job}
+		if job.HashKey != "" {
+			nodes, err := cluster.Nodes(job.ServerType)
+			if err != nil || len(nodes) == 0 {
+				m.retry(job, utils.IfElse(err != nil, err, errors.New("no available node")))
+				continue
+			}
+			due.serverID = nodes[idgen.HashToID64(job.HashKey)%uint64(len(nodes))]
+		}
+		if !m.TryAssign(due) {
+			// 模块队列已满或已停止 释放认领由下次轮询重新投递
+			if err := cluster.ReleaseJob(job); err != nil {
+				zlog.Errorf("sched release job %s error %v", id, err)
+			}
+		}
+	}
+}
+
+// 投递失败后退避重试 超过最大次数后丢弃
+// 可在任意协程调用
+func (m *module) retry(job *cluster.Job, reason error) {
+	if job.Attempts+1 >= m.maxAttempts {
+		zlog.Errorf("sched job %s discard after %d attempts, last error %v", job.ID, job.Attempts+1, reason)
+		if err := cluster.DeleteJob(job.ID); err != nil {
+			zlog.Errorf("sched delete job %s error %v", job.ID, err)
+		}
+		return
+	}
+	delay := m.retryDelay(job.Attempts)
+	zlog.Warnf("sched job %s retry after %v, error %v", job.ID, delay, reason)
+	if err := cluster.RetryJob(job, clock.Now().Add(delay)); err != nil {
+		zlog.Errorf("sched retry job %s error %v", job.ID, err)
+	}
+}
+
+// 第attempts次失败后的重试间隔 逐次翻倍到maxBackoff为止, 避免移位溢出
+func (m *module) retryDelay(attempts int) time.Duration {
+	delay := m.backoff
+	for i := 0; i < attempts && delay < m.maxBackoff; i++ {
+		delay *= 2
+	}
+	return utils.Min(delay, m.maxBackoff)
+}
@@ -8,6 +8,9 @@ import (
 	"github.com/tnnmigga/core/utils"
 )
 
+// 投递选项 供其他包保存或传递选项使用
+type CastOpt = castOpt
+
 type castOpt struct {
 	key   string
 	value any
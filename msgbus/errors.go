@@ -0,0 +1,64 @@
+package msgbus
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/tnnmigga/core/infra/zlog"
+)
+
+var (
+	errCodes   = map[string]error{}
+	errCodesRW sync.RWMutex
+)
+
+// 注册跨进程RPC保留类型的错误
+// 被调用方返回的错误满足errors.Is(err, target)时, 调用方收到的错误同样满足
+// code在所有进程中需一致, 一般在包的init中注册
+func RegisterError(code string, target error) {
+	errCodesRW.Lock()
+	defer errCodesRW.Unlock()
+	if old, ok := errCodes[code]; ok && old != target {
+		zlog.Panicf("msgbus error code duplicate registration %s", code)
+	}
+	errCodes[code] = target
+}
+
+// 返回错误对应的已注册code, 未注册时为空
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	errCodesRW.RLock()
+	defer errCodesRW.RUnlock()
+	for code, target := range errCodes {
+		if errors.Is(err, target) {
+			return code
+		}
+	}
+	return ""
+}
+
+// 按code还原错误 错误信息为msg, errors.Is可匹配注册的类型
+func CodeError(code, msg string) error {
+	errCodesRW.RLock()
+	target, ok := errCodes[code]
+	errCodesRW.RUnlock()
+	if !ok {
+		return errors.New(msg)
+	}
+	return &codeError{target: target, msg: msg}
+}
+
+type codeError struct {
+	target error
+	msg    string
+}
+
+func (e *codeError) Error() string {
+	return e.msg
+}
+
+func (e *codeError) Unwrap() error {
+	return e.target
+}
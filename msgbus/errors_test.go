@@ -0,0 +1,38 @@
+package msgbus
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCodeError(t *testing.T) {
+	errTest := errors.New("test sentinel")
+	RegisterError("test.sentinel", errTest)
+	cases := []struct {
+		name string
+		err  error
+		code string
+		is   bool
+	}{
+		{"sentinel", errTest, "test.sentinel", true},
+		{"wrapped", fmt.Errorf("%w: detail", errTest), "test.sentinel", true},
+		{"unregistered", errors.New("other"), "", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			code := ErrorCode(c.err)
+			if code != c.code {
+				t.Fatalf("code = %q, want %q", code, c.code)
+			}
+			// 模拟跨进程只传递code和错误信息
+			got := CodeError(code, c.err.Error())
+			if got.Error() != c.err.Error() {
+				t.Fatalf("error = %q, want %q", got, c.err)
+			}
+			if errors.Is(got, errTest) != c.is {
+				t.Fatalf("errors.Is = %v, want %v", !c.is, c.is)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mohae/deepcopy"
@@ -24,6 +25,7 @@ var (
 	recvers map[reflect.Type][]IRecver
 	rw      sync.RWMutex
 	// rpcMaxWaitTime time.Duration
+	rpcPending atomic.Int64 // 已发起但尚未回调的RPC数量
 )
 
 func init() {
@@ -65,9 +67,9 @@ func Cast(msg any, opts ...castOpt) {
 // 投递到本地其他协程
 // 跨进程投递靠本地link模块转发
 func castLocal(msg any, opts ...castOpt) {
-	recvs, ok := recvers[reflect.TypeOf(msg)]
+	recvs, ok := findRecvers(reflect.TypeOf(msg))
 	if !ok {
-		zlog.Errorf("message cast recv not fuound %v", utils.TypeName(msg))
+		zlog.LimitErrorf("message cast recv not fuound %v", utils.TypeName(msg))
 		return
 	}
 	modName := findCastOpt[idef.ModName](opts, idef.ConstKeyOneOfMods, "")
@@ -106,6 +108,10 @@ func Randomcast(serverType string, msg any) {
 func RPC[T any](caller idef.IModule, target castOpt, req any, cb func(resp T, err error)) {
 	// 跨协程传递消息默认深拷贝防止并发修改
 	req = deepcopy.Copy(req)
+	if _, ok := req.(idef.IAudited); ok {
+		zlog.Audit("rpc", "caller", caller.Name(), "target", target.value, "type", utils.TypeName(req), "req", req)
+	}
+	rpcPending.Add(1)
 	if target.key == idef.ConstKeyServerID && target.value.(uint32) == conf.ServerID {
 		localCall(caller, req, warpCb(cb))
 		return
@@ -121,6 +127,7 @@ func RPC[T any](caller idef.IModule, target castOpt, req any, cb func(resp T, er
 	} else if target.key == idef.ConstKeyServerType {
 		rpcCtx.ServerType = target.value.(string)
 	} else {
+		rpcPending.Add(-1)
 		zlog.Errorf("rpc target type error %v", target.value)
 		return
 	}
@@ -128,8 +135,9 @@ func RPC[T any](caller idef.IModule, target castOpt, req any, cb func(resp T, er
 }
 
 func localCall(m idef.IModule, req any, cb func(resp any, err error)) {
-	recvs, ok := recvers[reflect.TypeOf(req)]
+	recvs, ok := findRecvers(reflect.TypeOf(req))
 	if !ok {
+		rpcPending.Add(-1)
 		zlog.Errorf("recvs not fuound %v", utils.TypeName(req))
 		return
 	}
@@ -157,8 +165,27 @@ func localCall(m idef.IModule, req any, cb func(resp any, err error)) {
 	})
 }
 
+// RPC回调消息被接收模块丢弃时调用 修正未回调的RPC数量
+func DropRPC() {
+	rpcPending.Add(-1)
+}
+
+// 已发起但尚未回调的RPC数量
+func PendingRPC() int {
+	return int(rpcPending.Load())
+}
+
+// 模块可在运行时注册或注销 读取时需要加读锁
+func findRecvers(mType reflect.Type) ([]IRecver, bool) {
+	rw.RLock()
+	defer rw.RUnlock()
+	recvs, ok := recvers[mType]
+	return recvs, ok
+}
+
 func warpCb[T any](cb func(T, error)) func(any, error) {
 	return func(pkg any, err error) {
+		defer rpcPending.Add(-1)
 		if err != nil {
 			var empty T
 			cb(empty, err)
@@ -43,3 +43,38 @@ func registerRecver(mType reflect.Type, recver IRecver) {
 	}
 	recvers[mType] = append(recvers[mType], recver)
 }
+
+// 注销消息接收者
+// 移除该接收者在所有消息类型下的注册
+func UnregisterRecver(recver IRecver) {
+	rw.Lock()
+	defer rw.Unlock()
+	for mType, ms := range recvers {
+		remain := make([]IRecver, 0, len(ms))
+		for _, m := range ms {
+			if m.Name() != recver.Name() {
+				remain = append(remain, m)
+			}
+		}
+		if len(remain) == 0 {
+			delete(recvers, mType)
+			continue
+		}
+		recvers[mType] = remain
+	}
+}
+
+// 所有消息类型及其接收模块, key为消息名
+func Recvers() map[string][]idef.ModName {
+	rw.RLock()
+	defer rw.RUnlock()
+	res := make(map[string][]idef.ModName, len(recvers))
+	for mType, ms := range recvers {
+		names := make([]idef.ModName, 0, len(ms))
+		for _, m := range ms {
+			names = append(names, m.Name())
+		}
+		res[mType.Elem().Name()] = names
+	}
+	return res
+}
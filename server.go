@@ -1,10 +1,12 @@
 package nett
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tnnmigga/core/conc"
@@ -12,12 +14,17 @@ import (
 	"github.com/tnnmigga/core/infra/cluster"
 	"github.com/tnnmigga/core/infra/zlog"
 	"github.com/tnnmigga/core/mods/link"
+	"github.com/tnnmigga/core/msgbus"
 	"github.com/tnnmigga/core/utils"
 )
 
 type Server struct {
 	modules []idef.IModule
+	layers  [][]idef.IModule // 按依赖关系分层后的模块
 	wg      *sync.WaitGroup
+	mu      sync.Mutex   // 运行时增删模块与退出流程互斥
+	rw      sync.RWMutex // 保护modules和layers的读写
+	ready   atomic.Bool  // 是否可以接收流量
 }
 
 func NewServer(modules ...idef.IModule) *Server {
@@ -27,6 +34,12 @@ func NewServer(modules ...idef.IModule) *Server {
 	}
 	server.modules = append(server.modules, link.New()) // nats最后停止
 	server.modules = append(server.modules, modules...)
+	layers, err := sortModules(server.modules)
+	if err != nil {
+		zlog.Errorf("server sort modules error %v", err)
+		os.Exit(1)
+	}
+	server.layers = layers
 	server.onInit()
 	server.onRun()
 	return server
@@ -34,6 +47,7 @@ func NewServer(modules ...idef.IModule) *Server {
 
 func (s *Server) onInit() {
 	zlog.Warnf("server initialization")
+	zlog.HandleSignals()
 	err := cluster.Init()
 	if err != nil {
 		zlog.Errorf("cluster.InitNode error %v", err)
@@ -45,22 +59,24 @@ func (s *Server) onInit() {
 func (s *Server) onRun() {
 	s.before(idef.ServerStateRun, s.exit)
 	zlog.Warn("server try to run")
-	for _, m := range s.modules {
+	s.eachLayer(false, func(m idef.IModule) {
 		s.runModule(s.wg, m)
-	}
+	})
 	zlog.Warn("server running")
 	s.after(idef.ServerStateRun, s.exit)
+	s.ready.Store(true)
 }
 
 func (s *Server) onStop() {
+	s.ready.Store(false) // 先摘除流量再开始停止
+	s.closeIntake()
 	s.before(idef.ServerStateStop, s.record)
 	zlog.Warn("server try to stop")
-	s.waitMsgHandling(time.Minute)
+	s.drain(time.Minute, true, s.modules...)
 	conc.WaitGoDone(5 * time.Second)
-	for i := len(s.modules) - 1; i >= 0; i-- {
-		m := s.modules[i]
+	s.eachLayer(true, func(m idef.IModule) {
 		utils.ExecAndRecover(m.Stop)
-	}
+	})
 	s.wg.Wait()
 	zlog.Warn("server stoped")
 	s.after(idef.ServerStateStop, s.record)
@@ -73,10 +89,102 @@ func (s *Server) onExit() {
 }
 
 func (s *Server) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	defer s.onExit()
 	defer s.onStop()
 }
 
+// 运行时动态添加模块
+// 依次执行模块的初始化和运行阶段钩子后开始处理消息
+func (s *Server) AddModule(m idef.IModule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.findModule(m.Name()) != -1 {
+		return fmt.Errorf("module %s already exists", m.Name())
+	}
+	modules := append(s.modules[:len(s.modules):len(s.modules)], m)
+	layers, err := sortModules(modules)
+	if err != nil {
+		msgbus.UnregisterRecver(m)
+		return err
+	}
+	if err := s.execHooks(m, idef.ServerStateInit, 1); err != nil {
+		msgbus.UnregisterRecver(m)
+		return err
+	}
+	if err := s.execHooks(m, idef.ServerStateRun, 0); err != nil {
+		msgbus.UnregisterRecver(m)
+		return err
+	}
+	s.runModule(s.wg, m)
+	s.rw.Lock()
+	s.modules = modules
+	s.layers = layers
+	s.rw.Unlock()
+	if err := s.execHooks(m, idef.ServerStateRun, 1); err != nil {
+		// 模块已经在运行 由调用方决定是否卸载
+		return err
+	}
+	zlog.Warnf("module %s added", m.Name())
+	return nil
+}
+
+// 运行时卸载模块
+// 先注销消息接收 等待模块消息处理完后停止模块
+func (s *Server) RemoveModule(name idef.ModName) error {
+	if name == idef.ModLink {
+		return fmt.Errorf("module %s can not be removed", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.findModule(name)
+	if index == -1 {
+		return fmt.Errorf("module %s not found", name)
+	}
+	if names := dependents(s.modules, name); len(names) > 0 {
+		return fmt.Errorf("module %s is depended on by %v", name, names)
+	}
+	m := s.modules[index]
+	modules := append(s.modules[:index:index], s.modules[index+1:]...)
+	layers, err := sortModules(modules)
+	if err != nil {
+		return err
+	}
+	s.rw.Lock()
+	s.modules = modules
+	s.layers = layers
+	s.rw.Unlock()
+	// 钩子出错不中断卸载 模块已移出列表, 返回全部错误由调用方处理
+	errs := []error{s.execHooks(m, idef.ServerStateStop, 0)}
+	msgbus.UnregisterRecver(m)
+	s.drain(time.Minute, false, m)
+	utils.ExecAndRecover(m.Stop)
+	errs = append(errs, s.execHooks(m, idef.ServerStateStop, 1))
+	errs = append(errs, s.execHooks(m, idef.ServerStateExit, 0))
+	zlog.Warnf("module %s removed", name)
+	return errors.Join(errs...)
+}
+
+// 当前所有模块的快照
+func (s *Server) Modules() []idef.IModule {
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+	modules := make([]idef.IModule, len(s.modules))
+	copy(modules, s.modules)
+	return modules
+}
+
+// 查找模块索引, 不存在返回-1
+func (s *Server) findModule(name idef.ModName) int {
+	for i, m := range s.modules {
+		if m.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
 func (s *Server) runModule(wg *sync.WaitGroup, m idef.IModule) {
 	wg.Add(1)
 	go func() {
@@ -86,25 +194,6 @@ func (s *Server) runModule(wg *sync.WaitGroup, m idef.IModule) {
 	}()
 }
 
-func (s *Server) waitMsgHandling(maxWaitTime time.Duration) {
-	// 每100ms检查一次模块消息是否处理完
-	maxCheckCount := maxWaitTime / time.Millisecond / 100
-	for ; maxCheckCount > 0; maxCheckCount-- {
-		time.Sleep(100 * time.Millisecond)
-		isEmpty := true
-		for _, m := range s.modules {
-			if len(m.MQ()) != 0 {
-				isEmpty = false
-				break
-			}
-		}
-		if isEmpty {
-			return
-		}
-	}
-	zlog.Errorf("wait msg handing timeout")
-}
-
 // 不走退出流程直接退出进程
 func (s *Server) abort(m idef.IModule, err error) {
 	zlog.Errorf("module %s, on %s, error: %v", m.Name(), utils.Caller(3), err)
@@ -121,32 +210,38 @@ func (s *Server) record(m idef.IModule, err error) {
 	zlog.Errorf("module %s, on %s, error: %v", m.Name(), utils.Caller(3), err)
 }
 
+// 初始化和运行阶段按依赖顺序执行, 停止和退出阶段倒序执行
 func (s *Server) before(state idef.ServerState, onError ...func(idef.IModule, error)) {
-	for _, m := range s.modules {
-		hook := m.Hook(state, 0)
-		for _, h := range hook {
-			if err := wrapHook(h)(); err != nil {
-				zlog.Errorf("server before %#v error, module %s, error %v", state, m.Name(), err)
-				for _, f := range onError {
-					f(m, err)
-				}
+	s.eachLayer(state >= idef.ServerStateStop, func(m idef.IModule) {
+		if err := s.execHooks(m, state, 0); err != nil {
+			for _, f := range onError {
+				f(m, err)
 			}
 		}
-	}
+	})
 }
 
 func (s *Server) after(state idef.ServerState, onError ...func(idef.IModule, error)) {
-	for _, m := range s.modules {
-		hook := m.Hook(state, 1)
-		for _, h := range hook {
-			if err := wrapHook(h)(); err != nil {
-				zlog.Errorf("server before %#v error, module %s, error %v", state, m.Name(), err)
-				for _, f := range onError {
-					f(m, err)
-				}
+	s.eachLayer(state >= idef.ServerStateStop, func(m idef.IModule) {
+		if err := s.execHooks(m, state, 1); err != nil {
+			for _, f := range onError {
+				f(m, err)
+			}
+		}
+	})
+}
+
+// 执行单个模块某个阶段的钩子函数, 返回第一个错误
+func (s *Server) execHooks(m idef.IModule, state idef.ServerState, stage int) (err error) {
+	for _, h := range m.Hook(state, stage) {
+		if e := wrapHook(h)(); e != nil {
+			zlog.Errorf("server %s %#v error, module %s, error %v", utils.IfElse(stage == 0, "before", "after"), state, m.Name(), e)
+			if err == nil {
+				err = e
 			}
 		}
 	}
+	return err
 }
 
 // 添加panic处理
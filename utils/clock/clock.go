@@ -0,0 +1,102 @@
+package clock
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 时钟接口
+// 业务代码通过此包获取时间和创建定时器, 测试时替换为Fake即可控制时间流逝
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	Sleep(d time.Duration)
+}
+
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+type holder struct {
+	Clock
+}
+
+var current atomic.Pointer[holder]
+
+func init() {
+	current.Store(&holder{Real{}})
+}
+
+// 替换全局时钟 应在进程启动或测试开始时调用
+func Set(c Clock) {
+	current.Store(&holder{c})
+}
+
+// 当前全局时钟
+func Get() Clock {
+	return current.Load().Clock
+}
+
+func Now() time.Time {
+	return Get().Now()
+}
+
+func Since(t time.Time) time.Duration {
+	return Now().Sub(t)
+}
+
+func NewTimer(d time.Duration) Timer {
+	return Get().NewTimer(d)
+}
+
+func NewTicker(d time.Duration) Ticker {
+	return Get().NewTicker(d)
+}
+
+func Sleep(d time.Duration) {
+	Get().Sleep(d)
+}
+
+// 系统时钟
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (Real) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (Real) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
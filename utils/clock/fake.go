@@ -0,0 +1,201 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 手动推进的时钟
+// 时间只在调用Advance或Set时变化, 到期的定时器在调用协程中按到期顺序同步触发
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	seq     uint64
+	waiters []*fakeTimer
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1)}
+	f.mu.Lock()
+	f.schedule(t, d)
+	f.mu.Unlock()
+	f.Advance(0) // d<=0时立即触发
+	return t
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1), period: d}
+	f.mu.Lock()
+	f.schedule(t, d)
+	f.mu.Unlock()
+	return &fakeTicker{t}
+}
+
+// 阻塞到其他协程将时间推进d之后
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.NewTimer(d).C()
+}
+
+// 注册一个到期后在Advance协程中执行的函数
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	t := &fakeTimer{clock: f, fn: fn}
+	f.mu.Lock()
+	f.schedule(t, d)
+	f.mu.Unlock()
+	f.Advance(0)
+	return t
+}
+
+// 推进时间 依次触发期间到期的定时器
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	end := f.now.Add(d)
+	f.mu.Unlock()
+	f.advanceTo(end)
+}
+
+// 设置为指定时间 不能早于当前时间
+func (f *Fake) Set(t time.Time) {
+	f.advanceTo(t)
+}
+
+// 当前等待中的定时器数量
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+func (f *Fake) advanceTo(end time.Time) {
+	for {
+		f.mu.Lock()
+		if len(f.waiters) == 0 || f.waiters[0].when.After(end) {
+			if end.After(f.now) {
+				f.now = end
+			}
+			f.mu.Unlock()
+			return
+		}
+		t := f.waiters[0]
+		f.waiters = f.waiters[1:]
+		t.active = false
+		if t.when.After(f.now) {
+			f.now = t.when
+		}
+		now := f.now
+		if t.period > 0 {
+			f.schedule(t, t.period)
+		}
+		f.mu.Unlock()
+		// 在锁外触发 回调中可以继续操作时钟
+		t.fire(now)
+	}
+}
+
+// 调用方需持有锁
+func (f *Fake) schedule(t *fakeTimer, d time.Duration) {
+	f.remove(t)
+	f.seq++
+	t.when = f.now.Add(d)
+	t.seq = f.seq
+	t.active = true
+	i := sort.Search(len(f.waiters), func(i int) bool {
+		w := f.waiters[i]
+		return w.when.After(t.when) || (w.when.Equal(t.when) && w.seq > t.seq)
+	})
+	f.waiters = append(f.waiters, nil)
+	copy(f.waiters[i+1:], f.waiters[i:])
+	f.waiters[i] = t
+}
+
+// 调用方需持有锁
+func (f *Fake) remove(t *fakeTimer) bool {
+	if !t.active {
+		return false
+	}
+	t.active = false
+	for i, w := range f.waiters {
+		if w == t {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock  *Fake
+	c      chan time.Time
+	fn     func()
+	when   time.Time
+	seq    uint64
+	period time.Duration // 大于0时为ticker
+	active bool
+}
+
+func (t *fakeTimer) fire(now time.Time) {
+	if t.fn != nil {
+		t.fn()
+		return
+	}
+	// 与标准库一致 接收方未及时读取时丢弃
+	select {
+	case t.c <- now:
+	default:
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	active := t.active
+	t.clock.schedule(t, d)
+	t.clock.mu.Unlock()
+	t.clock.Advance(0)
+	return active
+}
+
+type fakeTicker struct {
+	t *fakeTimer
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.t.Stop()
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.t.clock.mu.Lock()
+	t.t.period = d
+	t.t.clock.schedule(t.t, d)
+	t.t.clock.mu.Unlock()
+}
@@ -0,0 +1,172 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var fakeStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeAdvanceOrder(t *testing.T) {
+	f := NewFake(fakeStart)
+	type fired struct {
+		name string
+		at   time.Duration
+	}
+	var got []fired
+	after := func(name string, d time.Duration) {
+		f.AfterFunc(d, func() {
+			got = append(got, fired{name, f.Now().Sub(fakeStart)})
+		})
+	}
+	after("c", 3*time.Second)
+	after("a", time.Second)
+	after("b1", 2*time.Second)
+	after("b2", 2*time.Second) // 同一时刻按注册顺序
+	after("d", 10*time.Second)
+	f.Advance(5 * time.Second)
+	want := []fired{{"a", time.Second}, {"b1", 2 * time.Second}, {"b2", 2 * time.Second}, {"c", 3 * time.Second}}
+	if len(got) != len(want) {
+		t.Fatalf("fired %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("fired %v, want %v", got, want)
+		}
+	}
+	if now := f.Now(); !now.Equal(fakeStart.Add(5 * time.Second)) {
+		t.Fatalf("now = %v, want %v", now, fakeStart.Add(5*time.Second))
+	}
+	if f.Waiters() != 1 {
+		t.Fatalf("waiters = %d, want 1", f.Waiters())
+	}
+}
+
+// 回调中新建的定时器在同一次Advance内按时间顺序触发
+func TestFakeAdvanceNested(t *testing.T) {
+	f := NewFake(fakeStart)
+	var got []string
+	f.AfterFunc(time.Second, func() {
+		got = append(got, "a")
+		f.AfterFunc(time.Second, func() { got = append(got, "c") })
+	})
+	f.AfterFunc(1500*time.Millisecond, func() { got = append(got, "b") })
+	f.Advance(3 * time.Second)
+	if len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Fatalf("fired %v, want [a b c]", got)
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	f := NewFake(fakeStart)
+	ticker := f.NewTicker(time.Second)
+	timer := f.NewTimer(1500 * time.Millisecond)
+	expect := func(c <-chan time.Time, at time.Duration) {
+		t.Helper()
+		select {
+		case now := <-c:
+			if !now.Equal(fakeStart.Add(at)) {
+				t.Fatalf("fired at %v, want %v", now.Sub(fakeStart), at)
+			}
+		default:
+			t.Fatalf("not fired, want at %v", at)
+		}
+	}
+	expectNone := func(c <-chan time.Time) {
+		t.Helper()
+		select {
+		case now := <-c:
+			t.Fatalf("unexpected fire at %v", now.Sub(fakeStart))
+		default:
+		}
+	}
+	f.Advance(time.Second)
+	expect(ticker.C(), time.Second)
+	expectNone(timer.C())
+	f.Advance(time.Second)
+	expect(ticker.C(), 2*time.Second)
+	expect(timer.C(), 1500*time.Millisecond)
+	// 未及时读取时丢弃 与标准库一致
+	f.Advance(3 * time.Second)
+	expect(ticker.C(), 3*time.Second)
+	expectNone(ticker.C())
+	ticker.Reset(2 * time.Second)
+	f.Advance(time.Second)
+	expectNone(ticker.C())
+	f.Advance(time.Second)
+	expect(ticker.C(), 7*time.Second)
+	ticker.Stop()
+	f.Advance(10 * time.Second)
+	expectNone(ticker.C())
+}
+
+func TestFakeTimerStopReset(t *testing.T) {
+	f := NewFake(fakeStart)
+	timer := f.NewTimer(time.Second)
+	if !timer.Stop() {
+		t.Fatal("stop active timer returns false")
+	}
+	if timer.Stop() {
+		t.Fatal("stop stopped timer returns true")
+	}
+	f.Advance(2 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+	if timer.Reset(time.Second) {
+		t.Fatal("reset stopped timer returns true")
+	}
+	f.Advance(time.Second)
+	select {
+	case now := <-timer.C():
+		if !now.Equal(fakeStart.Add(3 * time.Second)) {
+			t.Fatalf("fired at %v, want 3s", now.Sub(fakeStart))
+		}
+	default:
+		t.Fatal("reset timer not fired")
+	}
+	// 非正的时长立即触发
+	select {
+	case <-f.NewTimer(0).C():
+	default:
+		t.Fatal("zero timer not fired")
+	}
+}
+
+func TestFakeSleep(t *testing.T) {
+	f := NewFake(fakeStart)
+	done := make(chan time.Time)
+	go func() {
+		f.Sleep(time.Second)
+		done <- f.Now()
+	}()
+	deadline := time.Now().Add(time.Second)
+	for f.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("sleep not waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	f.Advance(999 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("woke up early")
+	case <-time.After(10 * time.Millisecond):
+	}
+	f.Advance(time.Millisecond)
+	if now := <-done; now.Before(fakeStart.Add(time.Second)) {
+		t.Fatalf("woke up at %v, want after 1s", now.Sub(fakeStart))
+	}
+}
+
+// 不能回退时间
+func TestFakeSetBackwards(t *testing.T) {
+	f := NewFake(fakeStart)
+	f.Set(fakeStart.Add(time.Hour))
+	f.Set(fakeStart)
+	if now := f.Now(); !now.Equal(fakeStart.Add(time.Hour)) {
+		t.Fatalf("now = %v, want %v", now, fakeStart.Add(time.Hour))
+	}
+}
@@ -3,28 +3,29 @@ package faketime
 import (
 	"strconv"
 	"sync/atomic"
-	"syscall"
 	"time"
 
-	"github.com/procodr/monkey"
-	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/utils/clock"
 )
 
 var addTime = &atomic.Int64{}
 
+// 在系统时钟基础上叠加偏移的时钟
+// 定时器按相对时长等待, 不受偏移影响
+type offsetClock struct {
+	clock.Real
+}
+
+func (offsetClock) Now() time.Time {
+	return FakeNow()
+}
+
 func FakeNow() time.Time {
-	t := &syscall.Timeval{}
-	syscall.Gettimeofday(t)
-	return time.Unix(t.Unix()).Add(time.Duration(addTime.Load()))
+	return time.Now().Add(time.Duration(addTime.Load()))
 }
 
 func init() {
-	defer func() {
-		if r := recover(); r != nil {
-			zlog.Errorf("faketime err %v", r)
-		}
-	}()
-	monkey.Patch(time.Now, FakeNow)
+	clock.Set(offsetClock{})
 }
 
 func AddFakeTime(arg string) {
@@ -0,0 +1,214 @@
+package segment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/tnnmigga/core/conf"
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/mods/mysql"
+	"github.com/tnnmigga/core/mods/redis"
+	"github.com/tnnmigga/core/msgbus"
+
+	goredis "github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// 号段 [Start, End)
+type Range struct {
+	Start uint64
+	End   uint64
+}
+
+func (r Range) Len() uint64 {
+	return r.End - r.Start
+}
+
+// 号段存储后端
+// 回调均由调用方模块协程执行
+type IBackend interface {
+	// 从全局序列租用一个长度为step的新号段
+	Lease(caller idef.IModule, name string, step uint64, cb func(Range, error))
+	// 保存本进程持有的号段 第一个号段的Start为预写标记
+	Save(caller idef.IModule, name string, owned []Range, cb func(error))
+	// 读取本进程上次持有的号段 用于重启后继续使用
+	Load(caller idef.IModule, name string, cb func([]Range, error))
+}
+
+// 同一序列的写操作保证时序
+func groupKey(name string) string {
+	return fmt.Sprintf("segment-%s", name)
+}
+
+// 通过redis模块分配号段
+// 全局序列保存在 Prefix:name, 进程持有的号段保存在 Prefix:name:owned:serverID
+type RedisBackend struct {
+	Prefix string
+}
+
+func (b *RedisBackend) key(name string) string {
+	return fmt.Sprintf("%s:%s", b.Prefix, name)
+}
+
+func (b *RedisBackend) ownedKey(name string) string {
+	return fmt.Sprintf("%s:%s:owned:%d", b.Prefix, name, conf.ServerID)
+}
+
+func (b *RedisBackend) Lease(caller idef.IModule, name string, step uint64, cb func(Range, error)) {
+	req := &redis.Exec{
+		Cmd: []any{"INCRBY", b.key(name), step},
+		Key: groupKey(name),
+	}
+	msgbus.RPC(caller, msgbus.Local(), req, func(res any, err error) {
+		if err != nil {
+			cb(Range{}, err)
+			return
+		}
+		end, ok := res.(int64)
+		if !ok {
+			cb(Range{}, fmt.Errorf("segment redis incrby result error %v", res))
+			return
+		}
+		cb(Range{Start: uint64(end) - step + 1, End: uint64(end) + 1}, nil)
+	})
+}
+
+func (b *RedisBackend) Save(caller idef.IModule, name string, owned []Range, cb func(error)) {
+	data, err := json.Marshal(owned)
+	if err != nil {
+		cb(err)
+		return
+	}
+	req := &redis.Exec{
+		Cmd: []any{"SET", b.ownedKey(name), data},
+		Key: groupKey(name),
+	}
+	msgbus.RPC(caller, msgbus.Local(), req, func(_ any, err error) {
+		cb(err)
+	})
+}
+
+func (b *RedisBackend) Load(caller idef.IModule, name string, cb func([]Range, error)) {
+	req := &redis.Exec{
+		Cmd: []any{"GET", b.ownedKey(name)},
+		Key: groupKey(name),
+	}
+	msgbus.RPC(caller, msgbus.Local(), req, func(res any, err error) {
+		if errors.Is(err, goredis.Nil) {
+			cb(nil, nil)
+			return
+		}
+		if err != nil {
+			cb(nil, err)
+			return
+		}
+		var owned []Range
+		s, _ := res.(string)
+		err = json.Unmarshal([]byte(s), &owned)
+		cb(owned, err)
+	})
+}
+
+// 通过mysql模块分配号段
+// 全局序列保存在表Table, 进程持有的号段保存在表Table_owned, 表不存在时自动创建
+// 基于ExecGORM实现, 只能在mysql模块所在进程使用
+type MySQLBackend struct {
+	Table   string
+	mu      sync.Mutex
+	ensured bool
+}
+
+// 建表成功后才标记 失败时下次调用重试
+func (b *MySQLBackend) ensureTables(db *gorm.DB) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ensured {
+		return nil
+	}
+	err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` ("+
+		"`name` VARCHAR(64) NOT NULL PRIMARY KEY,"+
+		"`max_id` BIGINT UNSIGNED NOT NULL DEFAULT 0)", b.Table)).Error
+	if err != nil {
+		return err
+	}
+	err = db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s_owned` ("+
+		"`name` VARCHAR(64) NOT NULL,"+
+		"`server_id` INT UNSIGNED NOT NULL,"+
+		"`ranges` TEXT NOT NULL,"+
+		"PRIMARY KEY (`name`, `server_id`))", b.Table)).Error
+	if err != nil {
+		return err
+	}
+	b.ensured = true
+	return nil
+}
+
+func (b *MySQLBackend) exec(caller idef.IModule, name string, fn func(*gorm.DB) (any, error), cb func(any, error)) {
+	req := &mysql.ExecGORM{
+		GroupKey: groupKey(name),
+		GORM: func(db *gorm.DB) (any, error) {
+			if err := b.ensureTables(db); err != nil {
+				return nil, err
+			}
+			return fn(db)
+		},
+	}
+	msgbus.RPC(caller, msgbus.Local(), req, cb)
+}
+
+func (b *MySQLBackend) Lease(caller idef.IModule, name string, step uint64, cb func(Range, error)) {
+	b.exec(caller, name, func(db *gorm.DB) (any, error) {
+		var r Range
+		err := db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Exec(fmt.Sprintf("INSERT INTO `%s` (`name`, `max_id`) VALUES (?, 0) ON DUPLICATE KEY UPDATE `name` = `name`", b.Table), name).Error
+			if err != nil {
+				return err
+			}
+			var maxID uint64
+			err = tx.Raw(fmt.Sprintf("SELECT `max_id` FROM `%s` WHERE `name` = ? FOR UPDATE", b.Table), name).Scan(&maxID).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Exec(fmt.Sprintf("UPDATE `%s` SET `max_id` = ? WHERE `name` = ?", b.Table), maxID+step, name).Error
+			r = Range{Start: maxID + 1, End: maxID + step + 1}
+			return err
+		})
+		return r, err
+	}, func(res any, err error) {
+		r, _ := res.(Range)
+		cb(r, err)
+	})
+}
+
+func (b *MySQLBackend) Save(caller idef.IModule, name string, owned []Range, cb func(error)) {
+	data, err := json.Marshal(owned)
+	if err != nil {
+		cb(err)
+		return
+	}
+	b.exec(caller, name, func(db *gorm.DB) (any, error) {
+		sql := fmt.Sprintf("INSERT INTO `%s_owned` (`name`, `server_id`, `ranges`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `ranges` = VALUES(`ranges`)", b.Table)
+		return nil, db.Exec(sql, name, conf.ServerID, string(data)).Error
+	}, func(_ any, err error) {
+		cb(err)
+	})
+}
+
+func (b *MySQLBackend) Load(caller idef.IModule, name string, cb func([]Range, error)) {
+	b.exec(caller, name, func(db *gorm.DB) (any, error) {
+		var data string
+		sql := fmt.Sprintf("SELECT `ranges` FROM `%s_owned` WHERE `name` = ? AND `server_id` = ?", b.Table)
+		err := db.Raw(sql, name, conf.ServerID).Scan(&data).Error
+		if err != nil || data == "" {
+			return []Range(nil), err
+		}
+		var owned []Range
+		err = json.Unmarshal([]byte(data), &owned)
+		return owned, err
+	}, func(res any, err error) {
+		owned, _ := res.([]Range)
+		cb(owned, err)
+	})
+}
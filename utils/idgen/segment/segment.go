@@ -0,0 +1,257 @@
+package segment
+
+import (
+	"time"
+
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/utils"
+	"github.com/tnnmigga/core/utils/clock"
+)
+
+// 号段分配器
+// 每个进程从后端租用号段在本地分配, 分配连续且不阻塞
+// 当前号段剩余不足一半时预加载下一个号段(双缓冲)
+// 本进程持有的号段连同预写标记保存在后端, 重启后从标记处继续使用, 最多跳过MarkStep个号
+// 所有方法只能在所属模块协程中调用
+type Allocator struct {
+	module   idef.IModule
+	backend  IBackend
+	step     uint64
+	markStep uint64
+	backoff  time.Duration
+	seqs     map[string]*sequence
+}
+
+type sequence struct {
+	name     string
+	owned    []Range // 已租用未分配的号段 owned[0]为当前号段
+	mark     uint64  // 已持久化的预写标记, 当前号段中小于mark的号可以分配
+	markEnd  uint64  // mark所属号段的End
+	loaded   bool    // 是否已从后端恢复
+	leasing  bool
+	saving   bool
+	retrying bool // 失败后等待重试
+	dirty    bool // 保存期间号段有变化, 保存完成后需要再次保存
+	failures int
+	waiters  []func(uint64, error)
+}
+
+type Option func(*Allocator)
+
+// 每次租用的号段长度 默认1000
+func WithStep(step uint64) Option {
+	return func(a *Allocator) {
+		a.step = step
+	}
+}
+
+// 预写标记的步长 默认为号段长度的1/10
+// 越大写后端越少, 重启后跳过的号越多
+func WithMarkStep(step uint64) Option {
+	return func(a *Allocator) {
+		a.markStep = step
+	}
+}
+
+// 后端失败后首次重试的间隔 之后翻倍 默认100毫秒
+func WithBackoff(backoff time.Duration) Option {
+	return func(a *Allocator) {
+		a.backoff = backoff
+	}
+}
+
+func New(m idef.IModule, backend IBackend, opts ...Option) *Allocator {
+	a := &Allocator{
+		module:  m,
+		backend: backend,
+		step:    1000,
+		backoff: 100 * time.Millisecond,
+		seqs:    map[string]*sequence{},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.markStep == 0 {
+		a.markStep = utils.Max(a.step/10, 1)
+	}
+	return a
+}
+
+// 提前加载序列 避免首次分配等待
+func (a *Allocator) Preload(names ...string) {
+	for _, name := range names {
+		a.sequence(name)
+	}
+}
+
+// 分配一个号 本地有可用号时同步回调, 否则等待号段加载后回调
+func (a *Allocator) Next(name string, cb func(id uint64, err error)) {
+	s := a.sequence(name)
+	if id, ok := a.take(s); ok {
+		cb(id, nil)
+		return
+	}
+	s.waiters = append(s.waiters, cb)
+}
+
+// 尝试同步分配一个号 本地没有可用号时返回false
+func (a *Allocator) TryNext(name string) (uint64, bool) {
+	return a.take(a.sequence(name))
+}
+
+func (a *Allocator) sequence(name string) *sequence {
+	s, ok := a.seqs[name]
+	if ok {
+		return s
+	}
+	s = &sequence{name: name}
+	a.seqs[name] = s
+	a.backend.Load(a.module, name, func(owned []Range, err error) {
+		if err != nil {
+			zlog.Errorf("segment %s load error %v", name, err)
+		}
+		for _, r := range owned {
+			if r.Len() > 0 {
+				s.owned = append(s.owned, r)
+			}
+		}
+		s.loaded = true
+		a.refill(s)
+	})
+	return s
+}
+
+func (a *Allocator) take(s *sequence) (uint64, bool) {
+	defer a.refill(s)
+	if len(s.owned) == 0 {
+		return 0, false
+	}
+	cur := &s.owned[0]
+	if cur.End != s.markEnd || cur.Start >= s.mark {
+		return 0, false // 预写标记尚未落盘
+	}
+	id := cur.Start
+	cur.Start++
+	if cur.Start == cur.End {
+		s.owned = s.owned[1:]
+	}
+	return id, true
+}
+
+// 按需租用号段/保存预写标记, 并处理等待中的分配
+func (a *Allocator) refill(s *sequence) {
+	if !s.loaded {
+		return
+	}
+	a.serve(s)
+	if s.retrying {
+		return
+	}
+	if !s.leasing && a.remain(s) <= a.step/2 {
+		a.lease(s)
+	}
+	if len(s.owned) == 0 {
+		return
+	}
+	// 切换号段或即将用到预写标记时保存新的标记
+	if cur := s.owned[0]; cur.End != s.markEnd || (s.mark < cur.End && cur.Start+a.markStep/2 >= s.mark) {
+		a.save(s)
+	}
+}
+
+func (a *Allocator) serve(s *sequence) {
+	for len(s.waiters) > 0 && len(s.owned) > 0 {
+		cur := &s.owned[0]
+		if cur.End != s.markEnd || cur.Start >= s.mark {
+			return
+		}
+		cb := s.waiters[0]
+		s.waiters = s.waiters[1:]
+		id := cur.Start
+		cur.Start++
+		if cur.Start == cur.End {
+			s.owned = s.owned[1:]
+		}
+		cb(id, nil)
+	}
+}
+
+func (a *Allocator) remain(s *sequence) (n uint64) {
+	for _, r := range s.owned {
+		n += r.Len()
+	}
+	return n
+}
+
+func (a *Allocator) lease(s *sequence) {
+	s.leasing = true
+	a.backend.Lease(a.module, s.name, a.step, func(r Range, err error) {
+		s.leasing = false
+		if err != nil {
+			zlog.Errorf("segment %s lease error %v", s.name, err)
+			a.fail(s, err)
+			return
+		}
+		s.failures = 0
+		s.owned = append(s.owned, r)
+		a.save(s) // 新号段需要记录 重启后才能继续使用
+		a.refill(s)
+	})
+}
+
+func (a *Allocator) save(s *sequence) {
+	if s.saving {
+		s.dirty = true
+		return
+	}
+	owned := make([]Range, len(s.owned))
+	copy(owned, s.owned)
+	cur := &owned[0]
+	mark := utils.Min(cur.Start+a.markStep, cur.End)
+	cur.Start = mark
+	end := cur.End
+	s.saving = true
+	s.dirty = false
+	a.backend.Save(a.module, s.name, owned, func(err error) {
+		s.saving = false
+		if err != nil {
+			zlog.Errorf("segment %s save error %v", s.name, err)
+			a.fail(s, err)
+			return
+		}
+		s.mark, s.markEnd = mark, end
+		if s.dirty || len(s.waiters) > 0 {
+			a.refill(s)
+		}
+	})
+}
+
+// 后端失败后退避重试, 连续失败时通知等待者 避免调用方无限等待
+func (a *Allocator) fail(s *sequence, err error) {
+	s.failures++
+	if s.failures < 3 {
+		a.retry(s, a.backoff<<(s.failures-1))
+		return
+	}
+	waiters := s.waiters
+	s.waiters = nil
+	s.failures = 0
+	for _, cb := range waiters {
+		cb(0, err)
+	}
+}
+
+func (a *Allocator) retry(s *sequence, delay time.Duration) {
+	if s.retrying {
+		return
+	}
+	s.retrying = true
+	a.module.Async(func() (any, error) {
+		clock.Sleep(delay)
+		return nil, nil
+	}, func(any, error) {
+		s.retrying = false
+		a.refill(s)
+	})
+}
@@ -0,0 +1,216 @@
+package segment
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tnnmigga/core/idef"
+	"github.com/tnnmigga/core/mods/basic"
+)
+
+// 同步执行Async 测试在当前协程内完成全部回调
+type syncModule struct {
+	*basic.Module
+}
+
+func (m syncModule) Async(f func() (any, error), cb func(any, error)) {
+	cb(f())
+}
+
+var modSeq atomic.Int32 // 模块名需唯一
+
+func newSyncModule(t *testing.T) idef.IModule {
+	name := idef.ModName(fmt.Sprintf("%s-%d", t.Name(), modSeq.Add(1)))
+	return syncModule{basic.New(name, basic.DefaultMQLen)}
+}
+
+// 内存后端 回调同步执行, holdLease/holdSave时对应回调挂起到手动释放
+type fakeBackend struct {
+	seq       uint64
+	owned     map[string][]Range
+	leases    int
+	saves     int
+	leaseErr  error
+	holdLease bool
+	holdSave  bool
+	held      []func()
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{owned: map[string][]Range{}}
+}
+
+func (b *fakeBackend) Lease(caller idef.IModule, name string, step uint64, cb func(Range, error)) {
+	b.leases++
+	done := func() {
+		if b.leaseErr != nil {
+			cb(Range{}, b.leaseErr)
+			return
+		}
+		r := Range{Start: b.seq + 1, End: b.seq + step + 1}
+		b.seq += step
+		cb(r, nil)
+	}
+	if b.holdLease {
+		b.held = append(b.held, done)
+		return
+	}
+	done()
+}
+
+func (b *fakeBackend) Save(caller idef.IModule, name string, owned []Range, cb func(error)) {
+	b.saves++
+	done := func() {
+		b.owned[name] = append([]Range(nil), owned...)
+		cb(nil)
+	}
+	if b.holdSave {
+		b.held = append(b.held, done)
+		return
+	}
+	done()
+}
+
+func (b *fakeBackend) Load(caller idef.IModule, name string, cb func([]Range, error)) {
+	cb(append([]Range(nil), b.owned[name]...), nil)
+}
+
+// 释放最早挂起的回调
+func (b *fakeBackend) release(t *testing.T) {
+	t.Helper()
+	if len(b.held) == 0 {
+		t.Fatal("no held callback")
+	}
+	done := b.held[0]
+	b.held = b.held[1:]
+	done()
+}
+
+func mustNext(t *testing.T, a *Allocator, name string) uint64 {
+	t.Helper()
+	id, ok := a.TryNext(name)
+	if !ok {
+		t.Fatalf("segment %s no id available", name)
+	}
+	return id
+}
+
+func TestLeaseHalfUsed(t *testing.T) {
+	b := newFakeBackend()
+	a := New(newSyncModule(t), b, WithStep(10), WithMarkStep(2))
+	a.Preload("uid")
+	if b.leases != 1 {
+		t.Fatalf("leases after preload = %d, want 1", b.leases)
+	}
+	for want := uint64(1); want <= 5; want++ {
+		if id := mustNext(t, a, "uid"); id != want {
+			t.Fatalf("id = %d, want %d", id, want)
+		}
+	}
+	// 剩余5个 不超过一半时预加载下一个号段
+	if b.leases != 2 {
+		t.Fatalf("leases after half used = %d, want 2", b.leases)
+	}
+	for want := uint64(6); want <= 20; want++ {
+		if id := mustNext(t, a, "uid"); id != want {
+			t.Fatalf("id = %d, want %d", id, want)
+		}
+	}
+}
+
+func TestSaveDirty(t *testing.T) {
+	b := newFakeBackend()
+	b.holdSave = true
+	a := New(newSyncModule(t), b, WithStep(10), WithMarkStep(2))
+	a.Preload("uid")
+	s := a.seqs["uid"]
+	if len(b.held) != 1 || !s.dirty {
+		t.Fatalf("held saves = %d dirty = %v, want 1 true", len(b.held), s.dirty)
+	}
+	if _, ok := a.TryNext("uid"); ok {
+		t.Fatal("id handed out before mark saved")
+	}
+	var got []uint64
+	a.Next("uid", func(id uint64, err error) {
+		if err != nil {
+			t.Errorf("next error %v", err)
+		}
+		got = append(got, id)
+	})
+	// 第一次保存完成后因dirty再次保存
+	b.release(t)
+	if len(b.held) != 1 || s.dirty {
+		t.Fatalf("held saves = %d dirty = %v, want 1 false", len(b.held), s.dirty)
+	}
+	if len(got) != 1 || got[0] != 1 {
+		t.Fatalf("waiter got %v, want [1]", got)
+	}
+	b.release(t)
+	if id := mustNext(t, a, "uid"); id != 2 {
+		t.Fatalf("id = %d, want 2", id)
+	}
+}
+
+func TestRestartResume(t *testing.T) {
+	b := newFakeBackend()
+	const markStep = 3
+	a := New(newSyncModule(t), b, WithStep(10), WithMarkStep(markStep))
+	seen := map[uint64]bool{}
+	var last uint64
+	for i := 0; i < 7; i++ {
+		last = mustNext(t, a, "uid")
+		seen[last] = true
+	}
+	// 重启 不保存当前进度 从预写标记处继续
+	a = New(newSyncModule(t), b, WithStep(10), WithMarkStep(markStep))
+	first := mustNext(t, a, "uid")
+	if first <= last || first > last+markStep+1 {
+		t.Fatalf("first id after restart = %d, want in (%d, %d]", first, last, last+markStep+1)
+	}
+	if seen[first] {
+		t.Fatalf("id %d handed out twice", first)
+	}
+	seen[first] = true
+	for i := 0; i < 30; i++ {
+		id := mustNext(t, a, "uid")
+		if seen[id] {
+			t.Fatalf("id %d handed out twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestFailNotifyWaiters(t *testing.T) {
+	b := newFakeBackend()
+	b.leaseErr = errors.New("backend down")
+	b.holdLease = true
+	a := New(newSyncModule(t), b, WithStep(10), WithBackoff(time.Millisecond))
+	var errs []error
+	a.Next("uid", func(id uint64, err error) {
+		errs = append(errs, err)
+	})
+	for i := 0; i < 3; i++ {
+		if len(errs) != 0 {
+			t.Fatalf("waiter notified after %d errors", i)
+		}
+		b.release(t) // 失败后退避重试
+	}
+	if b.leases != 3 || len(b.held) != 0 {
+		t.Fatalf("leases = %d, want 3", b.leases)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], b.leaseErr) {
+		t.Fatalf("waiter errors = %v, want [%v]", errs, b.leaseErr)
+	}
+	// 后端恢复后重新分配
+	b.leaseErr = nil
+	b.holdLease = false
+	if _, ok := a.TryNext("uid"); ok {
+		t.Fatal("id handed out without lease")
+	}
+	if id := mustNext(t, a, "uid"); id != 1 {
+		t.Fatalf("id after recover = %d, want 1", id)
+	}
+}
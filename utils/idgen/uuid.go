@@ -1,45 +1,230 @@
 package idgen
 
 import (
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/tnnmigga/core/conf"
+	"github.com/tnnmigga/core/infra/zlog"
+	"github.com/tnnmigga/core/utils/clock"
 )
 
-var uuidgen UUIDGenerater
+var (
+	ErrClockRollback = errors.New("idgen clock moved backwards")
+	ErrTimeOverflow  = errors.New("idgen timestamp overflow")
+)
+
+// 雪花算法参数
+// ID最高位恒为0, 其余依次为毫秒时间戳 | 节点 | 序号
+type SnowflakeOptions struct {
+	Epoch       time.Time     // 时间戳起点
+	NodeBits    uint          // 节点位数
+	SeqBits     uint          // 每毫秒序号位数
+	Node        uint64        // 节点号 通常为serverID
+	MaxBorrow   time.Duration // 序号用尽时最多向后借用的时长 超过后等待时钟追上
+	MaxRollback time.Duration // 可容忍的时钟回拨 期间沿用上次的时间戳, 超过返回ErrClockRollback
+}
+
+// 解析后的ID
+type IDInfo struct {
+	Time time.Time
+	Node uint64
+	Seq  uint64
+}
 
-type UUIDGenerater struct {
+type Snowflake struct {
 	sync.Mutex
-	timestamp uint64
-	index     uint64
+	opts    SnowflakeOptions
+	epochMs int64
+	maxTime int64
+	maxSeq  uint64
+	last    int64 // 上次分配的时间戳 可能因借用领先于时钟
+	wall    int64 // 上次观测到的时钟
+	seq     uint64
+}
+
+func NewSnowflake(opts SnowflakeOptions) (*Snowflake, error) {
+	if opts.NodeBits+opts.SeqBits >= 63 || opts.SeqBits == 0 {
+		return nil, fmt.Errorf("idgen invalid bits node %d seq %d", opts.NodeBits, opts.SeqBits)
+	}
+	if opts.Node >= 1<<opts.NodeBits {
+		return nil, fmt.Errorf("idgen node %d out of range, node bits %d", opts.Node, opts.NodeBits)
+	}
+	return &Snowflake{
+		opts:    opts,
+		epochMs: opts.Epoch.UnixMilli(),
+		maxTime: 1<<(63-opts.NodeBits-opts.SeqBits) - 1,
+		maxSeq:  1<<opts.SeqBits - 1,
+	}, nil
+}
+
+// 生成一个ID
+// 借用超过MaxBorrow时等待时钟追上, 等待期间不持有锁
+func (s *Snowflake) Next() (uint64, error) {
+	for {
+		s.Lock()
+		id, wait, err := s.next()
+		s.Unlock()
+		if err != nil || wait <= 0 {
+			return id, err
+		}
+		clock.Sleep(wait)
+	}
+}
+
+// 生成一个ID 时钟回拨超出容忍范围时等待时钟追上而不返回错误
+// 等待期间阻塞调用协程
+func (s *Snowflake) NextWait() (uint64, error) {
+	for {
+		id, err := s.Next()
+		if !errors.Is(err, ErrClockRollback) {
+			return id, err
+		}
+		s.Lock()
+		back := time.Duration(s.wall-s.now())*time.Millisecond - s.opts.MaxRollback
+		s.Unlock()
+		zlog.LimitErrorf("%v, wait %v", err, back)
+		clock.Sleep(back)
+	}
+}
+
+// 批量生成n个ID
+// 需要等待时钟时释放锁, 期间其他协程分配的ID可能穿插其中
+func (s *Snowflake) NextN(n int) ([]uint64, error) {
+	s.Lock()
+	defer s.Unlock()
+	ids := make([]uint64, 0, n)
+	for len(ids) < n {
+		id, wait, err := s.next()
+		if err != nil {
+			return ids, err
+		}
+		if wait > 0 {
+			s.Unlock()
+			clock.Sleep(wait)
+			s.Lock()
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// 解析ID中的时间戳/节点/序号
+func (s *Snowflake) Decode(id uint64) IDInfo {
+	shift := s.opts.NodeBits + s.opts.SeqBits
+	return IDInfo{
+		Time: time.UnixMilli(int64(id>>shift) + s.epochMs),
+		Node: (id >> s.opts.SeqBits) & (1<<s.opts.NodeBits - 1),
+		Seq:  id & s.maxSeq,
+	}
+}
+
+func (s *Snowflake) now() int64 {
+	return clock.Now().UnixMilli() - s.epochMs
+}
+
+// 分配一个ID 借用超过MaxBorrow时不分配, 返回需要等待的时长
+// 调用方持有锁 等待时需释放
+func (s *Snowflake) next() (uint64, time.Duration, error) {
+	now, err := s.observe()
+	if err != nil {
+		return 0, 0, err
+	}
+	last, seq := s.last, s.seq
+	if now > last {
+		last, seq = now, 0
+	} else if seq < s.maxSeq {
+		seq++
+	} else {
+		// 当前毫秒序号用尽 借用下一毫秒
+		last, seq = last+1, 0
+	}
+	if ahead := time.Duration(last-now) * time.Millisecond; ahead > s.opts.MaxBorrow {
+		return 0, ahead - s.opts.MaxBorrow, nil
+	}
+	if last > s.maxTime || last < 0 {
+		return 0, 0, ErrTimeOverflow
+	}
+	s.last, s.seq = last, seq
+	shift := s.opts.NodeBits + s.opts.SeqBits
+	return uint64(last)<<shift | s.opts.Node<<s.opts.SeqBits | seq, 0, nil
+}
+
+// 读取时钟并检测回拨
+func (s *Snowflake) observe() (int64, error) {
+	now := s.now()
+	if now < s.wall {
+		back := time.Duration(s.wall-now) * time.Millisecond
+		if back > s.opts.MaxRollback {
+			return 0, fmt.Errorf("%w %v", ErrClockRollback, back)
+		}
+		now = s.wall // 小幅回拨视为时钟未前进
+	}
+	s.wall = now
+	return now, nil
 }
 
-func (idgen *UUIDGenerater) NewID() uint64 {
-	idgen.Lock()
-	defer idgen.Unlock()
-	ms := newMs()
-	if ms != idgen.timestamp {
-		idgen.timestamp = ms
-		idgen.index = 0
+// 默认生成器 按配置创建, 节点号为serverID
+var snowflake = sync.OnceValues(func() (*Snowflake, error) {
+	return NewSnowflake(SnowflakeOptions{
+		Epoch:       time.UnixMilli(conf.Int64("idgen.epoch", 1700000000000)),
+		NodeBits:    uint(conf.Int("idgen.node-bits", 12)),
+		SeqBits:     uint(conf.Int("idgen.seq-bits", 10)),
+		Node:        uint64(conf.ServerID),
+		MaxBorrow:   time.Duration(conf.Int("idgen.max-borrow", 1000)) * time.Millisecond,
+		MaxRollback: time.Duration(conf.Int("idgen.max-rollback", 1000)) * time.Millisecond,
+	})
+})
+
+// 启动时检查默认生成器的配置 保证NewUUID不会因配置失败
+func init() {
+	s, err := snowflake()
+	if err != nil {
+		panic(err)
 	}
-	idgen.index++
-	if idgen.index > 0x3FF {
-		panic("idgen uuid index over limit")
+	if s.now() > s.maxTime {
+		panic(fmt.Errorf("%w, check idgen.epoch", ErrTimeOverflow))
+	}
+}
+
+// 默认生成器生成一个ID
+func Next() (uint64, error) {
+	s, err := snowflake()
+	if err != nil {
+		return 0, err
 	}
-	serverID := uint64(conf.ServerID)
-	if serverID >= 0xFFF {
-		panic("UUIDGenerater.NewID server-id must be smaller than 4096")
+	return s.Next()
+}
+
+// 默认生成器批量生成ID
+func NextN(n int) ([]uint64, error) {
+	s, err := snowflake()
+	if err != nil {
+		return nil, err
 	}
-	return idgen.timestamp<<40 | idgen.index | idgen.index<<10 | serverID
+	return s.NextN(n)
 }
 
-func newMs() uint64 {
-	ms := time.Now().UnixMilli()
-	return uint64(ms - 1700000000000)
+// 解析默认生成器生成的ID
+func Decode(id uint64) (IDInfo, error) {
+	s, err := snowflake()
+	if err != nil {
+		return IDInfo{}, err
+	}
+	return s.Decode(id), nil
 }
 
 // 雪花算法生成一个新的UUID
+// 时钟回拨超出容忍范围时等待时钟追上, 需要立即失败的调用方使用Next
+// 配置错误在启动时检查, 时间戳溢出(超出epoch可用年限)时panic
 func NewUUID() uint64 {
-	return uuidgen.NewID()
+	s, _ := snowflake()
+	id, err := s.NextWait()
+	if err != nil {
+		zlog.Panicf("idgen new uuid error %v", err)
+	}
+	return id
 }
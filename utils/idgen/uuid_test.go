@@ -0,0 +1,177 @@
+package idgen
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tnnmigga/core/utils/clock"
+)
+
+// 可以回拨的测试时钟 定时器由Fake推进
+type rollbackClock struct {
+	*clock.Fake
+	back atomic.Int64
+}
+
+func (c *rollbackClock) Now() time.Time {
+	return c.Fake.Now().Add(-time.Duration(c.back.Load()))
+}
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestSnowflake(t *testing.T, opts SnowflakeOptions) (*Snowflake, *rollbackClock) {
+	c := &rollbackClock{Fake: clock.NewFake(testStart)}
+	old := clock.Get()
+	clock.Set(c)
+	t.Cleanup(func() { clock.Set(old) })
+	opts.Epoch = testStart.Add(-time.Second)
+	s, err := NewSnowflake(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, c
+}
+
+// 等待协程进入clock.Sleep
+func waitSleeping(t *testing.T, c *rollbackClock) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for c.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("generator not waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func sameInfo(a, b IDInfo) bool {
+	return a.Time.Equal(b.Time) && a.Node == b.Node && a.Seq == b.Seq
+}
+
+func mustNext(t *testing.T, s *Snowflake) uint64 {
+	t.Helper()
+	id, err := s.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestSnowflakeLayout(t *testing.T) {
+	s, c := newTestSnowflake(t, SnowflakeOptions{NodeBits: 4, SeqBits: 3, Node: 5})
+	id := mustNext(t, s)
+	// 时间戳1000ms | 节点5 | 序号0
+	if want := uint64(1000)<<7 | 5<<3; id != want {
+		t.Fatalf("id = %b, want %b", id, want)
+	}
+	if id>>63 != 0 {
+		t.Fatalf("id %d sign bit set", id)
+	}
+	id = mustNext(t, s)
+	if want := (IDInfo{Time: c.Now(), Node: 5, Seq: 1}); !sameInfo(s.Decode(id), want) {
+		t.Fatalf("decode = %+v, want %+v", s.Decode(id), want)
+	}
+	c.Advance(time.Millisecond)
+	id = mustNext(t, s)
+	if want := (IDInfo{Time: c.Now(), Node: 5, Seq: 0}); !sameInfo(s.Decode(id), want) {
+		t.Fatalf("decode after advance = %+v, want %+v", s.Decode(id), want)
+	}
+}
+
+func TestSnowflakeInvalid(t *testing.T) {
+	cases := []SnowflakeOptions{
+		{NodeBits: 40, SeqBits: 23},
+		{NodeBits: 4, SeqBits: 0},
+		{NodeBits: 4, SeqBits: 3, Node: 16},
+	}
+	for _, opts := range cases {
+		if _, err := NewSnowflake(opts); err == nil {
+			t.Errorf("options %+v no error", opts)
+		}
+	}
+}
+
+func TestSnowflakeBorrow(t *testing.T) {
+	s, c := newTestSnowflake(t, SnowflakeOptions{NodeBits: 4, SeqBits: 2, MaxBorrow: 2 * time.Millisecond})
+	now := c.Now()
+	// 每毫秒4个序号 最多借用到now+2ms
+	var last uint64
+	for i := 0; i < 12; i++ {
+		id := mustNext(t, s)
+		if id <= last {
+			t.Fatalf("id %d not increasing after %d", id, last)
+		}
+		last = id
+		want := IDInfo{Time: now.Add(time.Duration(i/4) * time.Millisecond), Seq: uint64(i % 4)}
+		if info := s.Decode(id); !sameInfo(info, want) {
+			t.Fatalf("id %d decode = %+v, want %+v", i, info, want)
+		}
+	}
+	// 借用超过MaxBorrow 等待时钟追上且不持有锁
+	done := make(chan uint64)
+	go func() {
+		id, _ := s.Next()
+		done <- id
+	}()
+	waitSleeping(t, c)
+	if !s.TryLock() {
+		t.Fatal("generator holds lock while waiting")
+	}
+	s.Unlock()
+	c.Advance(time.Millisecond)
+	id := <-done
+	if want := (IDInfo{Time: now.Add(3 * time.Millisecond)}); !sameInfo(s.Decode(id), want) {
+		t.Fatalf("decode after wait = %+v, want %+v", s.Decode(id), want)
+	}
+}
+
+func TestSnowflakeRollback(t *testing.T) {
+	s, c := newTestSnowflake(t, SnowflakeOptions{NodeBits: 4, SeqBits: 3, MaxRollback: 5 * time.Millisecond})
+	now := c.Now()
+	first := mustNext(t, s)
+	// 容忍范围内 沿用上次的时间戳
+	c.back.Store(int64(3 * time.Millisecond))
+	id := mustNext(t, s)
+	if want := (IDInfo{Time: now, Seq: 1}); id <= first || !sameInfo(s.Decode(id), want) {
+		t.Fatalf("decode in tolerance = %+v, want %+v", s.Decode(id), want)
+	}
+	c.back.Store(int64(10 * time.Millisecond))
+	if _, err := s.Next(); !errors.Is(err, ErrClockRollback) {
+		t.Fatalf("next error = %v, want %v", err, ErrClockRollback)
+	}
+	// 等待时钟回到容忍范围内
+	done := make(chan uint64)
+	go func() {
+		id, _ := s.NextWait()
+		done <- id
+	}()
+	waitSleeping(t, c)
+	c.Advance(5 * time.Millisecond)
+	id = <-done
+	if want := (IDInfo{Time: now, Seq: 2}); !sameInfo(s.Decode(id), want) {
+		t.Fatalf("decode after wait = %+v, want %+v", s.Decode(id), want)
+	}
+}
+
+func TestSnowflakeNextN(t *testing.T) {
+	s, c := newTestSnowflake(t, SnowflakeOptions{NodeBits: 4, SeqBits: 2})
+	done := make(chan []uint64)
+	go func() {
+		ids, _ := s.NextN(6)
+		done <- ids
+	}()
+	// 第5个ID需要借用 MaxBorrow为0时等待下一毫秒
+	waitSleeping(t, c)
+	c.Advance(time.Millisecond)
+	ids := <-done
+	if len(ids) != 6 {
+		t.Fatalf("ids = %v, want 6", ids)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("ids %v not increasing", ids)
+		}
+	}
+}
@@ -19,6 +19,16 @@ func String(v any) string {
 	return fmt.Sprintf("[ %s: %v ]", TypeName(v), v)
 }
 
+// 延迟格式化
+// 作为日志参数时只有真正输出才会调用String序列化
+type Lazy struct {
+	V any
+}
+
+func (l Lazy) String() string {
+	return String(l.V)
+}
+
 // 零拷贝字节数组转字符串
 // 如果不确定是否存在并发则不使用
 func BytesToString(b []byte) string {
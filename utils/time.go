@@ -1,13 +1,17 @@
 package utils
 
-import "time"
+import (
+	"time"
+
+	"github.com/tnnmigga/core/utils/clock"
+)
 
 // 获取纳秒级时间戳
 func NowNs() time.Duration {
-	return time.Duration(time.Now().UnixNano())
+	return time.Duration(clock.Now().UnixNano())
 }
 
 // 获取秒级时间戳
 func NowSec() float64 {
-	return float64(time.Now().UnixNano()) / float64(time.Second)
+	return float64(clock.Now().UnixNano()) / float64(time.Second)
 }